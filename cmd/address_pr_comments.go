@@ -2,12 +2,11 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 
+	"github.com/bsaliba1/jira-claude/internal/archive"
 	"github.com/bsaliba1/jira-claude/internal/claude"
-	"github.com/bsaliba1/jira-claude/internal/git"
-	"github.com/bsaliba1/jira-claude/internal/github"
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	"github.com/bsaliba1/jira-claude/internal/templates"
 	pkgerrors "github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -22,8 +21,9 @@ var (
 var addressPRCommentsCmd = &cobra.Command{
 	Use:   "address-pr-comments",
 	Short: "Address PR review comments using Claude",
-	Long: `Fetches PR review comments from GitHub, uses Claude to address them by making
-code changes, and commits/pushes the changes.
+	Long: `Fetches PR review feedback from GitHub (inline review comments, review summaries
+and conversation comments), uses Claude to address it by making code changes,
+and commits/pushes the changes.
 
 If no PR number is provided, it will attempt to detect the PR from the current branch.`,
 	RunE: runAddressPRComments,
@@ -35,45 +35,68 @@ func init() {
 	addressPRCommentsCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Preview without making changes")
 	addressPRCommentsCmd.Flags().StringVarP(&flagPromptPrefix, "prompt-prefix", "p", "", "Additional context for Claude")
 	addressPRCommentsCmd.Flags().BoolVar(&flagNoPush, "no-push", false, "Skip automatic push after commit")
-	addressPRCommentsCmd.Flags().BoolVar(&flagWithReplies, "with-replies", false, "Post reply summaries to comments after making changes")
+	addressPRCommentsCmd.Flags().BoolVar(&flagWithReplies, "with-replies", false, "Post reply summaries to inline comments after making changes")
 }
 
-func runAddressPRComments(cmd *cobra.Command, args []string) error {
+func runAddressPRComments(cmd *cobra.Command, args []string) (err error) {
 	ctx := cmd.Context()
 	l := log.Ctx(ctx)
 
-	// Resolve repository path
-	repoPath := flagRepo
-	if repoPath == "" {
-		cwd, err := os.Getwd()
-		if err != nil {
-			return pkgerrors.Wrap(err, "failed to get current directory")
-		}
-		repoPath = cwd
+	repoPath, err := resolveRepoPath(flagRepo)
+	if err != nil {
+		return err
 	}
-	repoPath, err := filepath.Abs(repoPath)
+
+	conf, err := loadSettings(repoPath)
 	if err != nil {
-		return pkgerrors.Wrap(err, "failed to resolve repository path")
+		return err
+	}
+	tmpls, err := loadTemplates(conf.Templates, repoPath)
+	if err != nil {
+		return err
 	}
 
-	ghClient := github.New(repoPath)
-	gitClient := git.New(repoPath)
+	ghClient, err := newGitHub(ctx, conf, repoPath)
+	if err != nil {
+		return err
+	}
+	gitClient := newGit(conf.Timeouts, repoPath)
 
 	// Determine PR number
 	prNumber := flagPRNumber
 	if prNumber == 0 {
 		l.Info().Msg("detecting PR from current branch")
-		detected, err := ghClient.GetPRForBranch()
+		branch, err := gitClient.CurrentBranch(ctx)
+		if err != nil {
+			return err
+		}
+		detected, err := ghClient.GetPRForBranch(ctx, branch)
 		if err != nil {
 			return pkgerrors.Wrap(err, "failed to detect PR (use --pr to specify)")
 		}
 		prNumber = detected
 	}
 
+	runs, err := newRunStore(conf.State)
+	if err != nil {
+		return err
+	}
+	run := startArchive(ctx, runs, "address-pr-comments", fmt.Sprintf("pr-%d", prNumber), repoPath)
+	status := "no changes"
+	defer func() {
+		if err != nil {
+			status = archive.StatusFailed
+		}
+		if archiveErr := run.Finish(status, err); archiveErr != nil {
+			l.Warn().Err(archiveErr).Msg("failed to archive run")
+		}
+	}()
+
+	run.Step("fetching comments")
 	l.Info().Int("pr", prNumber).Msg("fetching PR comments")
 
 	// Fetch PR comments
-	comments, err := ghClient.GetPRComments(prNumber)
+	comments, err := ghClient.GetPRComments(ctx, prNumber)
 	if err != nil {
 		return pkgerrors.Wrap(err, "failed to fetch PR comments")
 	}
@@ -83,11 +106,16 @@ func runAddressPRComments(cmd *cobra.Command, args []string) error {
 		fmt.Println("No review comments found on this PR.")
 		return nil
 	}
+	run.Update(func(r *archive.Run) { r.PRURL = comments.PRURL })
 
-	l.Info().Int("count", len(comments.Comments)).Msg("found review comments")
+	l.Info().
+		Int("inline", len(comments.Inline())).
+		Int("reviews", len(comments.Reviews())).
+		Int("conversation", len(comments.Conversation())).
+		Msg("found review comments")
 
 	// Check git state
-	hasChanges, err := gitClient.HasChanges()
+	hasChanges, err := gitClient.HasChanges(ctx)
 	if err != nil {
 		return pkgerrors.Wrap(err, "failed to check git status")
 	}
@@ -95,26 +123,55 @@ func runAddressPRComments(cmd *cobra.Command, args []string) error {
 		l.Warn().Msg("working directory has uncommitted changes")
 	}
 
-	// Format comments as prompt
-	prompt := github.FormatCommentsAsPrompt(comments, flagPromptPrefix)
+	// Format comments as prompt. Without --prompt-prefix, the prefix of the
+	// project whose ticket the PR is for applies.
+	promptPrefix := flagPromptPrefix
+	if promptPrefix == "" {
+		promptPrefix = conf.ForProject(jira.ProjectKeyOf(jira.TicketKeyIn(comments.PRTitle))).PromptPrefix
+	}
+	commentsData := templates.PRCommentsData{PR: comments, PromptPrefix: promptPrefix}
+	prompt, err := tmpls.Render(templates.PRCommentsPrompt, commentsData)
+	if err != nil {
+		return err
+	}
+	archiveFile(ctx, run, archive.PromptFile, prompt)
 
 	if flagDryRun {
 		l.Info().Msg("[dry-run] would invoke Claude with the following prompt:")
 		fmt.Println("\n--- PROMPT ---")
 		fmt.Println(prompt)
 		fmt.Println("--- END PROMPT ---")
+		status = "dry run"
 		return nil
 	}
 
+	defer archiveDiffOnReturn(ctx, run, gitClient)()
+
 	// Invoke Claude
+	transcript, err := run.Append(archive.TranscriptFile)
+	if err != nil {
+		return err
+	}
+	defer transcript.Close()
+
+	run.Step("running Claude")
 	l.Info().Msg("invoking Claude Code to address comments")
-	claudeClient := claude.New(repoPath)
-	if err := claudeClient.Run(prompt); err != nil {
+	claudeClient := newClaude(conf, repoPath, claude.WithTranscript(transcript))
+	claudeRun, err := claudeClient.Run(ctx, prompt)
+	reportClaudeRun(ctx, claudeRun)
+	if claudeRun != nil {
+		run.Update(func(r *archive.Run) {
+			r.SessionID = claudeRun.SessionID
+			r.CostUSD = claudeRun.CostUSD
+		})
+	}
+	if err != nil {
 		return pkgerrors.Wrap(err, "Claude Code failed")
 	}
 
 	// Check for changes
-	hasChanges, err = gitClient.HasChanges()
+	run.Step("committing changes")
+	hasChanges, err = gitClient.HasChanges(ctx)
 	if err != nil {
 		return pkgerrors.Wrap(err, "failed to check for changes")
 	}
@@ -126,18 +183,22 @@ func runAddressPRComments(cmd *cobra.Command, args []string) error {
 	}
 
 	// Commit changes
-	commitMsg := fmt.Sprintf("Address PR #%d review comments\n\nAddressed by Claude Code", prNumber)
-	if err := gitClient.AddAll(); err != nil {
+	commitMsg, err := tmpls.Render(templates.PRCommentsCommitMessage, commentsData)
+	if err != nil {
+		return err
+	}
+	if err := gitClient.AddAll(ctx); err != nil {
 		return pkgerrors.Wrap(err, "failed to stage changes")
 	}
-	if err := gitClient.Commit(commitMsg); err != nil {
+	if err := gitClient.Commit(ctx, commitMsg); err != nil {
 		return pkgerrors.Wrap(err, "failed to commit changes")
 	}
 	l.Info().Msg("committed changes")
 
 	// Push unless --no-push
 	if !flagNoPush {
-		if err := gitClient.Push(); err != nil {
+		run.Step("pushing changes")
+		if err := gitClient.Push(ctx); err != nil {
 			return pkgerrors.Wrap(err, "failed to push changes")
 		}
 		l.Info().Msg("pushed changes to origin")
@@ -147,16 +208,19 @@ func runAddressPRComments(cmd *cobra.Command, args []string) error {
 
 	// Post replies if requested
 	if flagWithReplies {
+		run.Step("posting replies")
 		l.Info().Msg("posting replies to comments")
 		replyBody := "Addressed in latest commit."
-		for _, comment := range comments.Comments {
-			if err := ghClient.ReplyToComment(prNumber, comment.ID, replyBody); err != nil {
+		// Only inline comments have threads to reply in.
+		for _, comment := range comments.Inline() {
+			if err := ghClient.ReplyToComment(ctx, prNumber, comment.ID, replyBody); err != nil {
 				l.Warn().Err(err).Int64("commentID", comment.ID).Msg("failed to post reply")
 			}
 		}
-		l.Info().Msg("posted replies to all comments")
+		l.Info().Msg("posted replies to inline comments")
 	}
 
+	status = "addressed"
 	l.Info().Msg("finished addressing PR comments")
 	fmt.Printf("\nSuccessfully addressed %d review comments on PR #%d\n", len(comments.Comments), prNumber)
 	fmt.Printf("PR: %s\n", comments.PRURL)
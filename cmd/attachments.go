@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// downloadAttachments fetches the ticket's attachments into a scratch
+// directory outside the repository and records their local paths on the
+// ticket. It returns the directory, or "" if nothing was downloaded, and a
+// cleanup func that removes it.
+func downloadAttachments(ctx context.Context, env *workEnv, ticket *jira.Ticket) (string, func(), error) {
+	l := log.Ctx(ctx)
+	noop := func() {}
+
+	var wanted []int
+	for i, a := range ticket.Attachments {
+		if !env.conf.AttachmentAllowed(a.MimeType, a.Size) {
+			l.Info().
+				Str("file", a.Filename).
+				Str("mimeType", a.MimeType).
+				Int64("size", a.Size).
+				Msg("skipping attachment (type or size not allowed)")
+			continue
+		}
+		wanted = append(wanted, i)
+	}
+
+	if len(wanted) == 0 {
+		return "", noop, nil
+	}
+
+	if flagDryRun {
+		l.Info().Int("count", len(wanted)).Msg("[dry-run] would download attachments")
+		return "", noop, nil
+	}
+
+	dir, err := os.MkdirTemp("", "jira-claude-"+ticket.Key+"-")
+	if err != nil {
+		return "", noop, pkgerrors.Wrap(err, "failed to create attachment directory")
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			l.Warn().Err(err).Str("dir", dir).Msg("failed to remove attachment directory")
+		}
+	}
+
+	downloaded := 0
+	for _, i := range wanted {
+		a := &ticket.Attachments[i]
+		path, err := env.jira.DownloadAttachment(*a, dir)
+		if err != nil {
+			l.Warn().Err(err).Str("file", a.Filename).Msg("failed to download attachment")
+			continue
+		}
+		a.Path = path
+		downloaded++
+	}
+
+	l.Info().Int("count", downloaded).Str("dir", dir).Msg("downloaded attachments")
+
+	if downloaded == 0 {
+		cleanup()
+		return "", noop, nil
+	}
+
+	return dir, cleanup, nil
+}
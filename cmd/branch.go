@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bsaliba1/jira-claude/internal/config"
+	"github.com/bsaliba1/jira-claude/internal/git"
+	"github.com/bsaliba1/jira-claude/internal/github"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// maxBranchSuffix bounds the search for a free suffixed branch name.
+const maxBranchSuffix = 20
+
+// existingBranch records where a feature branch name is already in use.
+type existingBranch struct {
+	name   string
+	local  bool
+	remote bool
+	// prURL is the open PR whose head is the branch, if any.
+	prURL string
+}
+
+func (b existingBranch) exists() bool {
+	return b.local || b.remote
+}
+
+func (b existingBranch) String() string {
+	var where []string
+	if b.local {
+		where = append(where, "locally")
+	}
+	if b.remote {
+		where = append(where, "on origin")
+	}
+	s := fmt.Sprintf("branch %s already exists %s", b.name, strings.Join(where, " and "))
+	if b.prURL != "" {
+		s += " with open PR " + b.prURL
+	}
+	return s
+}
+
+// branchPlan is how the workspace should get onto the feature branch.
+type branchPlan struct {
+	existingBranch
+	// reuse checks out the existing branch instead of creating it from base.
+	reuse bool
+	// deleteLocal deletes the existing local branch before recreating it.
+	deleteLocal bool
+}
+
+// inspectBranch looks for the branch locally, on origin and as the head of
+// an open PR.
+func inspectBranch(ctx context.Context, gitClient *git.Git, ghClient github.Client, name string) (existingBranch, error) {
+	b := existingBranch{name: name, local: gitClient.BranchExists(ctx, "refs/heads/"+name)}
+
+	remote, err := gitClient.RemoteBranchExists(ctx, name)
+	if err != nil {
+		return b, pkgerrors.Wrap(err, "failed to check for a remote branch")
+	}
+	b.remote = remote
+
+	// A PR cannot stay open once its head branch is gone from origin.
+	if b.remote {
+		prURL, err := ghClient.OpenPRForBranch(ctx, name)
+		if err != nil {
+			return b, err
+		}
+		b.prURL = prURL
+	}
+	return b, nil
+}
+
+// branchState is what was found out about a feature branch name that is
+// needed to apply the existing branch policy.
+type branchState struct {
+	existing existingBranch
+	// suffixed holds the suffixed names inspected in order, ending with the
+	// first free one if there is one.
+	suffixed []existingBranch
+	// unsafe says why recreating the existing branch could lose work, or is
+	// "" if it could not.
+	unsafe string
+}
+
+// planBranch decides, according to the existing branch policy, which branch
+// the ticket is implemented on when name is already taken. base is the ref
+// a new branch would be cut from.
+func planBranch(ctx context.Context, env *workEnv, gitClient *git.Git, name, base string) (*branchPlan, error) {
+	l := log.Ctx(ctx)
+	policy := env.conf.ExistingBranch
+
+	ghClient, err := newGitHub(ctx, env.conf, env.repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := inspectBranch(ctx, gitClient, ghClient, name)
+	if err != nil {
+		return nil, err
+	}
+	state := branchState{existing: existing}
+
+	if existing.exists() {
+		l.Info().Str("branch", name).Bool("local", existing.local).Bool("remote", existing.remote).
+			Str("pr", existing.prURL).Str("policy", string(policy)).Msg("feature branch already exists")
+
+		// Only look up what the policy needs.
+		switch policy {
+		case config.ExistingBranchSuffix:
+			for i := 2; i <= maxBranchSuffix; i++ {
+				candidate, err := inspectBranch(ctx, gitClient, ghClient, fmt.Sprintf("%s-%d", name, i))
+				if err != nil {
+					return nil, err
+				}
+				state.suffixed = append(state.suffixed, candidate)
+				if !candidate.exists() {
+					break
+				}
+			}
+		case config.ExistingBranchRecreate:
+			if existing.prURL == "" {
+				if state.unsafe, err = unsafeToRecreate(ctx, gitClient, existing, base); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	plan, err := decideBranch(policy, state)
+	if err != nil {
+		return nil, err
+	}
+	if plan.name != name {
+		l.Info().Str("branch", plan.name).Msg("using suffixed branch name")
+	} else if existing.exists() && !plan.reuse {
+		l.Info().Str("branch", name).Msg("existing branch has no work on it, recreating")
+	}
+	return plan, nil
+}
+
+// decideBranch applies the existing branch policy to what was found out
+// about the branch. It never plans to recreate a branch that has an open PR
+// or commits beyond base.
+func decideBranch(policy config.ExistingBranchPolicy, state branchState) (*branchPlan, error) {
+	existing := state.existing
+	if !existing.exists() {
+		return &branchPlan{existingBranch: existing}, nil
+	}
+
+	switch policy {
+	case config.ExistingBranchReuse:
+		return &branchPlan{existingBranch: existing, reuse: true}, nil
+
+	case config.ExistingBranchSuffix:
+		for _, candidate := range state.suffixed {
+			if !candidate.exists() {
+				return &branchPlan{existingBranch: candidate}, nil
+			}
+		}
+		return nil, fmt.Errorf("no free branch name: %s through %s-%d are all taken", existing.name, existing.name, maxBranchSuffix)
+
+	case config.ExistingBranchRecreate:
+		if existing.prURL != "" {
+			return nil, fmt.Errorf("%s, so it was not recreated; use --existing-branch reuse or suffix", existing)
+		}
+		if state.unsafe != "" {
+			return nil, fmt.Errorf("%s and %s, so it was not recreated; use --existing-branch reuse or suffix", existing, state.unsafe)
+		}
+		return &branchPlan{existingBranch: existing, deleteLocal: existing.local}, nil
+	}
+
+	return nil, fmt.Errorf("%s; use --existing-branch reuse, suffix or recreate", existing)
+}
+
+// unsafeToRecreate returns why recreating the branch from base could lose
+// work, or "" if neither the local nor the remote branch has commits beyond
+// base.
+func unsafeToRecreate(ctx context.Context, gitClient *git.Git, b existingBranch, base string) (string, error) {
+	if b.local {
+		ahead, err := gitClient.CommitsAhead(ctx, base, "refs/heads/"+b.name)
+		if err != nil {
+			return "", pkgerrors.Wrap(err, "failed to compare existing branch with base")
+		}
+		if ahead > 0 {
+			return fmt.Sprintf("it has %d local commit(s) beyond %s", ahead, base), nil
+		}
+	}
+
+	if b.remote {
+		if err := gitClient.FetchBranch(ctx, b.name); err != nil {
+			return "", pkgerrors.Wrap(err, "failed to fetch existing remote branch")
+		}
+		ahead, err := gitClient.CommitsAhead(ctx, base, "origin/"+b.name)
+		if err != nil {
+			return "", pkgerrors.Wrap(err, "failed to compare remote branch with base")
+		}
+		if ahead > 0 {
+			return fmt.Sprintf("it has %d commit(s) on origin beyond %s", ahead, base), nil
+		}
+	}
+
+	return "", nil
+}
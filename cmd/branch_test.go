@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bsaliba1/jira-claude/internal/config"
+)
+
+const testBranch = "feature/SUI-1-add-widgets"
+
+// taken returns the suffixed names -2 through -last, all in use locally.
+func taken(last int) []existingBranch {
+	var branches []existingBranch
+	for i := 2; i <= last; i++ {
+		branches = append(branches, existingBranch{name: fmt.Sprintf("%s-%d", testBranch, i), local: true})
+	}
+	return branches
+}
+
+func TestDecideBranch(t *testing.T) {
+	local := existingBranch{name: testBranch, local: true}
+	remote := existingBranch{name: testBranch, remote: true}
+	both := existingBranch{name: testBranch, local: true, remote: true}
+	withPR := existingBranch{name: testBranch, remote: true, prURL: "https://github.com/acme/widgets/pull/7"}
+
+	tests := []struct {
+		name    string
+		policy  config.ExistingBranchPolicy
+		state   branchState
+		want    branchPlan
+		wantErr string
+	}{
+		{
+			name:   "new branch under any policy",
+			policy: config.ExistingBranchAbort,
+			state:  branchState{existing: existingBranch{name: testBranch}},
+			want:   branchPlan{existingBranch: existingBranch{name: testBranch}},
+		},
+		{
+			name:    "abort",
+			policy:  config.ExistingBranchAbort,
+			state:   branchState{existing: local},
+			wantErr: "branch " + testBranch + " already exists locally; use --existing-branch",
+		},
+		{
+			name:   "reuse local branch",
+			policy: config.ExistingBranchReuse,
+			state:  branchState{existing: local},
+			want:   branchPlan{existingBranch: local, reuse: true},
+		},
+		{
+			name:   "reuse branch with open PR",
+			policy: config.ExistingBranchReuse,
+			state:  branchState{existing: withPR},
+			want:   branchPlan{existingBranch: withPR, reuse: true},
+		},
+		{
+			name:   "suffix picks the first free name",
+			policy: config.ExistingBranchSuffix,
+			state: branchState{
+				existing: both,
+				suffixed: append(taken(3), existingBranch{name: testBranch + "-4"}),
+			},
+			want: branchPlan{existingBranch: existingBranch{name: testBranch + "-4"}},
+		},
+		{
+			name:    "suffix runs out of names",
+			policy:  config.ExistingBranchSuffix,
+			state:   branchState{existing: local, suffixed: taken(maxBranchSuffix)},
+			wantErr: fmt.Sprintf("no free branch name: %s through %s-%d are all taken", testBranch, testBranch, maxBranchSuffix),
+		},
+		{
+			name:   "recreate local branch without work",
+			policy: config.ExistingBranchRecreate,
+			state:  branchState{existing: local},
+			want:   branchPlan{existingBranch: local, deleteLocal: true},
+		},
+		{
+			name:   "recreate remote-only branch without work",
+			policy: config.ExistingBranchRecreate,
+			state:  branchState{existing: remote},
+			want:   branchPlan{existingBranch: remote},
+		},
+		{
+			name:    "recreate refuses a branch with an open PR",
+			policy:  config.ExistingBranchRecreate,
+			state:   branchState{existing: withPR},
+			wantErr: "with open PR https://github.com/acme/widgets/pull/7, so it was not recreated",
+		},
+		{
+			name:    "recreate refuses local commits ahead",
+			policy:  config.ExistingBranchRecreate,
+			state:   branchState{existing: local, unsafe: "it has 2 local commit(s) beyond main"},
+			wantErr: "already exists locally and it has 2 local commit(s) beyond main, so it was not recreated",
+		},
+		{
+			name:    "recreate refuses remote commits ahead",
+			policy:  config.ExistingBranchRecreate,
+			state:   branchState{existing: both, unsafe: "it has 1 commit(s) on origin beyond main"},
+			wantErr: "it has 1 commit(s) on origin beyond main, so it was not recreated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := decideBranch(tt.policy, tt.state)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decideBranch() = %+v, %v; want error containing %q", plan, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decideBranch() error = %v", err)
+			}
+			if *plan != tt.want {
+				t.Errorf("decideBranch() = %+v, want %+v", *plan, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bsaliba1/jira-claude/internal/claude"
+	"github.com/rs/zerolog/log"
+)
+
+// reportClaudeRun logs the cost and size of a Claude run and prints Claude's
+// final message.
+func reportClaudeRun(ctx context.Context, run *claude.RunResult) {
+	if run == nil {
+		return
+	}
+
+	log.Ctx(ctx).Info().
+		Str("sessionID", run.SessionID).
+		Float64("costUSD", run.CostUSD).
+		Int("turns", run.NumTurns).
+		Dur("duration", run.Duration).
+		Int("inputTokens", run.InputTokens).
+		Int("outputTokens", run.OutputTokens).
+		Int("filesEdited", len(run.FilesEdited)).
+		Msg("Claude Code finished")
+
+	if msg := strings.TrimSpace(run.Result); msg != "" {
+		fmt.Printf("\n--- Claude's summary ---\n%s\n------------------------\n", msg)
+	}
+}
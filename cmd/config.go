@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/bsaliba1/jira-claude/internal/claude"
+	"github.com/bsaliba1/jira-claude/internal/config"
+	"github.com/bsaliba1/jira-claude/internal/git"
+	"github.com/bsaliba1/jira-claude/internal/github"
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	"github.com/bsaliba1/jira-claude/internal/templates"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// resolveRepoPath returns the absolute path of the repository given by the
+// --repo flag, defaulting to the current directory.
+func resolveRepoPath(repoPath string) (string, error) {
+	if repoPath == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", pkgerrors.Wrap(err, "failed to get current directory")
+		}
+		repoPath = cwd
+	}
+	repoPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to resolve repository path")
+	}
+	return repoPath, nil
+}
+
+// loadConfig loads the layered configuration for the repository and checks
+// that the Jira credentials are set.
+func loadConfig(repoPath string) (config.Config, error) {
+	conf, err := loadSettings(repoPath)
+	if err != nil {
+		return conf, err
+	}
+	if err := conf.Validate(); err != nil {
+		return conf, pkgerrors.Wrap(err, "failed to load configuration (check the config files and JIRA_CLAUDE_* env vars)")
+	}
+	return conf, nil
+}
+
+// loadSettings loads the layered configuration for the repository without
+// requiring Jira credentials, for commands that do not talk to Jira.
+func loadSettings(repoPath string) (config.Config, error) {
+	loaded, err := config.Load(config.LoadOptions{RepoPath: repoPath, Profile: flagProfile})
+	if err != nil {
+		return config.Config{}, pkgerrors.Wrap(err, "failed to load configuration")
+	}
+	return loaded.Config, nil
+}
+
+// newJiraClient creates a Jira client from the configured credentials.
+func newJiraClient(conf config.Config) (*jira.JiraClient, error) {
+	client, err := jira.NewClient(conf.JiraHost, conf.JiraUsername, conf.JiraAPIToken)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create Jira client")
+	}
+	return client, nil
+}
+
+// loadTemplates loads the built-in templates, overridden by those in the
+// global template directory and then the repository's .jira-claude/templates.
+func loadTemplates(conf config.Templates, repoPath string) (*templates.Set, error) {
+	globalDir, err := conf.TemplateRoot()
+	if err != nil {
+		return nil, err
+	}
+	return templates.Load(globalDir, filepath.Join(repoPath, templates.RepoDir))
+}
+
+// newGit creates a git client for dir with the configured timeout.
+func newGit(t config.Timeouts, dir string) *git.Git {
+	return git.New(dir, git.WithTimeout(t.GitTimeout))
+}
+
+// newGitHub creates the GitHub client for the repository at dir. Unless
+// github_client is "gh", that is the REST client, authenticated with the
+// configured token or the one gh is logged in with; in "auto" mode the gh
+// CLI is used when no token is available.
+func newGitHub(ctx context.Context, conf config.Config, dir string) (github.Client, error) {
+	gh := github.New(dir, github.WithTimeout(conf.GitHubTimeout))
+	if conf.GitHubClient == config.GitHubClientGH {
+		return gh, nil
+	}
+
+	client, err := newGitHubREST(ctx, conf, dir, gh)
+	if err != nil {
+		if conf.GitHubClient == config.GitHubClientAPI {
+			return nil, pkgerrors.Wrap(err, "failed to create GitHub API client")
+		}
+		log.Ctx(ctx).Debug().Err(err).Msg("GitHub API unavailable, using the gh CLI")
+		return gh, nil
+	}
+	return client, nil
+}
+
+// newGitHubREST creates a REST client for the repository origin points to.
+func newGitHubREST(ctx context.Context, conf config.Config, dir string, gh *github.GitHub) (*github.RESTClient, error) {
+	remote, err := newGit(conf.Timeouts, dir).GetRemoteURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := github.ParseRemote(remote)
+	if err != nil {
+		return nil, err
+	}
+
+	token := conf.GitHubToken
+	if token == "" {
+		if token, err = gh.Token(ctx, repo.Host); err != nil {
+			return nil, err
+		}
+	}
+
+	return github.NewRESTClient(repo, token,
+		github.WithBaseURL(conf.GitHubAPIURL),
+		github.WithRequestTimeout(conf.GitHubTimeout),
+	), nil
+}
+
+// newClaude creates a Claude client for dir with the configured timeout and
+// Claude options.
+func newClaude(conf config.Config, dir string, opts ...claude.Option) *claude.Claude {
+	base := []claude.Option{
+		claude.WithTimeout(conf.ClaudeTimeout),
+		claude.WithModel(conf.ClaudeModel),
+		claude.WithMaxTurns(conf.ClaudeMaxTurns),
+		claude.WithAllowedTools(conf.ClaudeAllowedTools...),
+	}
+	return claude.New(dir, append(base, opts...)...)
+}
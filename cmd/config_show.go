@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bsaliba1/jira-claude/internal/config"
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the layered configuration",
+	Long: `Settings are read in layers, each overriding the one before:
+
+  1. built-in defaults
+  2. the global config file (~/.config/jira-claude/config.yaml)
+  3. the repository's .jira-claude.yaml
+  4. the selected profile (--profile, JIRA_CLAUDE_PROFILE or the files' "profile"
+     key), from the global file and then the repository file
+  5. JIRA_CLAUDE_* environment variables
+
+Settings under "projects" apply only to tickets in that Jira project.`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each value comes from",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigShow,
+}
+
+func init() {
+	configShowCmd.Flags().StringVarP(&flagRepo, "repo", "r", "", "Path to git repository (defaults to current directory)")
+
+	configCmd.AddCommand(configShowCmd)
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	repoPath, err := resolveRepoPath(flagRepo)
+	if err != nil {
+		return err
+	}
+
+	loaded, err := config.Load(config.LoadOptions{RepoPath: repoPath, Profile: flagProfile})
+	if err != nil {
+		return err
+	}
+
+	if len(loaded.Files) == 0 {
+		fmt.Println("Config files: none")
+	} else {
+		fmt.Println("Config files:")
+		for _, path := range loaded.Files {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	if loaded.Profile != "" {
+		fmt.Printf("Profile: %s\n", loaded.Profile)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, s := range loaded.Config.Settings() {
+		if s.Key == "projects" {
+			continue
+		}
+		value := formatSetting(s.Value())
+		if s.Secret && value != "" {
+			value = "********"
+		}
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, truncate(value, 80), loaded.Sources[s.Key])
+	}
+
+	keys := make([]string, 0, len(loaded.Config.Projects))
+	for key := range loaded.Config.Projects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		prefix := "projects." + key + "."
+		var fields []string
+		for field := range loaded.Sources {
+			if strings.HasPrefix(field, prefix) {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+
+		values := loaded.Config.Projects[key].Values()
+		for _, field := range fields {
+			value := formatSetting(values[strings.TrimPrefix(field, prefix)])
+			fmt.Fprintf(w, "%s\t%s\t%s\n", field, truncate(value, 80), loaded.Sources[field])
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if err := loaded.Config.Validate(); err != nil {
+		fmt.Printf("\nWarning: %v\n", err)
+	}
+	return nil
+}
+
+// formatSetting renders a setting's value the way it would be written in
+// an environment variable.
+func formatSetting(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ",")
+	case map[string]string:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = k + ":" + v[k]
+		}
+		return strings.Join(pairs, ",")
+	case *string:
+		// A project status set to "" skips the transition.
+		if v == nil {
+			return ""
+		}
+		if *v == "" {
+			return `""`
+		}
+		return *v
+	case config.FieldMaps:
+		if len(v) == 0 {
+			return ""
+		}
+		return formatJSON(v)
+	case *jira.FieldMap:
+		if v == nil {
+			return ""
+		}
+		return formatJSON(v)
+	}
+	return fmt.Sprint(value)
+}
+
+func formatJSON(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
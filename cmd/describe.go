@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/bsaliba1/jira-claude/internal/claude"
+	"github.com/bsaliba1/jira-claude/internal/describe"
+	"github.com/bsaliba1/jira-claude/internal/git"
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	"github.com/bsaliba1/jira-claude/internal/templates"
+	"github.com/bsaliba1/jira-claude/internal/verify"
+	"github.com/rs/zerolog/log"
+)
+
+// describeChanges asks Claude to write the commit message and PR description
+// from the staged diff. It returns nil when disabled or when Claude's reply
+// cannot be used, and the commit_message and pr_body templates fall back to
+// their canned text.
+func describeChanges(ctx context.Context, env *workEnv, dir string, gitClient *git.Git, ticket *jira.Ticket, verification *verify.Report) *describe.Description {
+	l := log.Ctx(ctx)
+
+	if !env.conf.DescribeChanges {
+		return nil
+	}
+
+	diff, err := gitClient.StagedDiff(ctx)
+	if err != nil {
+		l.Warn().Err(err).Msg("failed to read staged diff, using default commit message")
+		return nil
+	}
+
+	prompt, err := env.templates.Render(templates.DescribePrompt, describe.PromptData(ticket, diff, verification))
+	if err != nil {
+		l.Warn().Err(err).Msg("failed to render describe prompt, using default commit message")
+		return nil
+	}
+
+	l.Info().Msg("asking Claude to describe the changes")
+	output, err := newClaude(env.conf, dir, claude.WithReadOnly()).RunWithOutput(ctx, prompt)
+	if err != nil {
+		l.Warn().Err(err).Msg("Claude failed to describe the changes, using default commit message")
+		return nil
+	}
+
+	desc, err := describe.Parse(output)
+	if err != nil {
+		l.Warn().Err(err).Msg("could not parse Claude's description, using default commit message")
+		return nil
+	}
+
+	return desc
+}
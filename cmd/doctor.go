@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bsaliba1/jira-claude/internal/config"
+	"github.com/bsaliba1/jira-claude/internal/github"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that Jira, GitHub, Claude Code and git are ready for a run",
+	Long: `Checks every dependency of the work command: the configuration, Jira
+credentials, GitHub access, the claude binary, git's identity, the origin
+remote and the base branch. Each failed check comes with a hint on fixing it,
+and the command exits non-zero if any check fails.
+
+The work command runs the quick checks before it touches the repository.`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+func init() {
+	doctorCmd.Flags().StringVarP(&flagRepo, "repo", "r", "", "Path to git repository (defaults to current directory)")
+	doctorCmd.Flags().StringVarP(&flagBaseBranch, "base-branch", "b", "", "Base branch to look for on origin (defaults to config or 'main')")
+}
+
+type checkStatus string
+
+const (
+	checkPass checkStatus = "ok"
+	checkFail checkStatus = "FAIL"
+	checkSkip checkStatus = "skipped"
+)
+
+// checkResult is the outcome of one doctor check. Hint says how to fix a
+// failure.
+type checkResult struct {
+	Name   string
+	Status checkStatus
+	Detail string
+	Hint   string
+}
+
+// doctorEnv is what the checks inspect.
+type doctorEnv struct {
+	conf     config.Config
+	confErr  error
+	repoPath string
+	base     string
+}
+
+// check is one dependency check. Quick checks are cheap enough to run
+// before every work command; repo checks inspect the repository rather than
+// the tools.
+type check struct {
+	name  string
+	quick bool
+	repo  bool
+	run   func(ctx context.Context, env *doctorEnv) checkResult
+}
+
+var checks = []check{
+	{name: "config", quick: true, run: checkConfig},
+	{name: "jira", run: checkJira},
+	{name: "github", quick: true, run: checkGitHub},
+	{name: "claude", quick: true, run: checkClaude},
+	{name: "git identity", quick: true, repo: true, run: checkGitIdentity},
+	{name: "origin remote", quick: true, repo: true, run: checkRemote},
+	{name: "base branch", repo: true, run: checkBaseBranch},
+}
+
+func pass(detail string) checkResult {
+	return checkResult{Status: checkPass, Detail: detail}
+}
+
+func fail(err error, hint string) checkResult {
+	return checkResult{Status: checkFail, Detail: err.Error(), Hint: hint}
+}
+
+func skip(reason string) checkResult {
+	return checkResult{Status: checkSkip, Detail: reason}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	repoPath, err := resolveRepoPath(flagRepo)
+	if err != nil {
+		return err
+	}
+
+	env := &doctorEnv{repoPath: repoPath, base: flagBaseBranch}
+	env.conf, env.confErr = loadSettings(repoPath)
+	if env.confErr == nil {
+		env.confErr = env.conf.Validate()
+	}
+	if env.base == "" {
+		env.base = env.conf.DefaultBaseBranch
+	}
+
+	results := runChecks(ctx, env, func(check) bool { return true })
+	printChecks(results)
+
+	if failed := countFailed(results); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	fmt.Println("\nAll checks passed.")
+	return nil
+}
+
+// runChecks runs the checks selected by include, in order.
+func runChecks(ctx context.Context, env *doctorEnv, include func(check) bool) []checkResult {
+	var results []checkResult
+	for _, c := range checks {
+		if !include(c) {
+			continue
+		}
+		result := c.run(ctx, env)
+		result.Name = c.name
+		results = append(results, result)
+	}
+	return results
+}
+
+func countFailed(results []checkResult) int {
+	failed := 0
+	for _, r := range results {
+		if r.Status == checkFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// printChecks prints a table of check results followed by the hints for
+// the failed ones.
+func printChecks(results []checkResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tDETAILS")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.Status, truncate(firstLine(r.Detail), 100))
+	}
+	w.Flush()
+
+	var hints []string
+	for _, r := range results {
+		if r.Status == checkFail && r.Hint != "" {
+			hints = append(hints, fmt.Sprintf("  %s: %s", r.Name, r.Hint))
+		}
+	}
+	if len(hints) > 0 {
+		fmt.Println("\nTo fix:")
+		fmt.Println(strings.Join(hints, "\n"))
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// preflight runs the quick checks before work touches the repository. With
+// skipRepo, only the tools are checked, for runs whose tickets are routed to
+// other repositories.
+func preflight(ctx context.Context, env *workEnv, skipRepo bool) error {
+	return runPreflight(ctx, &doctorEnv{conf: env.conf, repoPath: env.repoPath}, func(c check) bool {
+		return c.quick && !(skipRepo && c.repo)
+	})
+}
+
+// preflightRepo runs the quick repository checks for a repository a ticket
+// was routed to.
+func preflightRepo(ctx context.Context, env *workEnv) error {
+	return runPreflight(ctx, &doctorEnv{conf: env.conf, repoPath: env.repoPath}, func(c check) bool {
+		return c.quick && c.repo
+	})
+}
+
+func runPreflight(ctx context.Context, env *doctorEnv, include func(check) bool) error {
+	var problems []string
+	for _, r := range runChecks(ctx, env, include) {
+		if r.Status != checkFail {
+			continue
+		}
+		problem := fmt.Sprintf("%s: %s", r.Name, firstLine(r.Detail))
+		if r.Hint != "" {
+			problem += " (" + r.Hint + ")"
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if flagDryRun {
+		for _, p := range problems {
+			log.Ctx(ctx).Warn().Msg("[dry-run] check failed: " + p)
+		}
+		return nil
+	}
+	return fmt.Errorf("checks failed, run jira-claude doctor for details:\n  %s", strings.Join(problems, "\n  "))
+}
+
+func checkConfig(ctx context.Context, env *doctorEnv) checkResult {
+	if env.confErr != nil {
+		return fail(env.confErr, "set the missing values in a config file or JIRA_CLAUDE_* env vars, and see jira-claude config show")
+	}
+	return pass("valid")
+}
+
+func checkJira(ctx context.Context, env *doctorEnv) checkResult {
+	if env.confErr != nil {
+		return skip("configuration is invalid")
+	}
+	client, err := newJiraClient(env.conf)
+	if err != nil {
+		return fail(err, "check jira_host")
+	}
+	user, err := client.Myself()
+	if err != nil {
+		return fail(err, "check jira_host, jira_username and jira_api_token; tokens are created at https://id.atlassian.com/manage-profile/security/api-tokens")
+	}
+	return pass(fmt.Sprintf("%s as %s", env.conf.JiraHost, user))
+}
+
+func checkGitHub(ctx context.Context, env *doctorEnv) checkResult {
+	client, err := newGitHub(ctx, env.conf, env.repoPath)
+	if err != nil {
+		return fail(err, "set github_token, or run gh auth login")
+	}
+
+	if _, ok := client.(*github.GitHub); ok {
+		if _, err := exec.LookPath("gh"); err != nil {
+			return fail(err, "install the GitHub CLI from https://cli.github.com, or set github_token to use the API")
+		}
+		if err := client.AuthStatus(ctx); err != nil {
+			return fail(err, "run gh auth login")
+		}
+		return pass("gh CLI, logged in")
+	}
+
+	if err := client.AuthStatus(ctx); err != nil {
+		return fail(err, "check github_token and github_api_url, or run gh auth login")
+	}
+	return pass("REST API, token accepted")
+}
+
+func checkClaude(ctx context.Context, env *doctorEnv) checkResult {
+	if _, err := exec.LookPath("claude"); err != nil {
+		return fail(err, "install Claude Code with npm install -g @anthropic-ai/claude-code")
+	}
+	version, err := newClaude(env.conf, env.repoPath).Version(ctx)
+	if err != nil {
+		return fail(err, "reinstall Claude Code or check that claude --version runs")
+	}
+	return pass(version)
+}
+
+func checkGitIdentity(ctx context.Context, env *doctorEnv) checkResult {
+	gitClient := newGit(env.conf.Timeouts, env.repoPath)
+	var identity []string
+	for _, key := range []string{"user.name", "user.email"} {
+		value, err := gitClient.ConfigValue(ctx, key)
+		if err != nil || value == "" {
+			return fail(fmt.Errorf("%s is not set", key), fmt.Sprintf("run git config --global %s <value>", key))
+		}
+		identity = append(identity, value)
+	}
+	return pass(fmt.Sprintf("%s <%s>", identity[0], identity[1]))
+}
+
+func checkRemote(ctx context.Context, env *doctorEnv) checkResult {
+	url, err := newGit(env.conf.Timeouts, env.repoPath).GetRemoteURL(ctx)
+	if err != nil {
+		return fail(pkgerrors.Wrapf(err, "no origin remote in %s", env.repoPath), "run from a clone of the repository, or git remote add origin <url>")
+	}
+	return pass(url)
+}
+
+func checkBaseBranch(ctx context.Context, env *doctorEnv) checkResult {
+	gitClient := newGit(env.conf.Timeouts, env.repoPath)
+	if _, err := gitClient.GetRemoteURL(ctx); err != nil {
+		return skip("no origin remote")
+	}
+	exists, err := gitClient.RemoteBranchExists(ctx, env.base)
+	if err != nil {
+		return fail(err, "check that origin is reachable and you have access to it")
+	}
+	if !exists {
+		return fail(fmt.Errorf("%s does not exist on origin", env.base), "set default_base_branch or pass --base-branch")
+	}
+	return pass(env.base)
+}
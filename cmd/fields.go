@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagFieldsProject string
+	flagFieldsAll     bool
+)
+
+var fieldsCmd = &cobra.Command{
+	Use:   "fields",
+	Short: "List Jira fields and their IDs",
+	Long: `Lists the custom fields defined in Jira along with their IDs, to help set up
+a project's "fields" configuration.
+
+With --project, only fields available on that project's issue types are listed.`,
+	RunE: runFields,
+}
+
+func init() {
+	fieldsCmd.Flags().StringVar(&flagFieldsProject, "project", "", "Jira project key to list fields for (e.g., PROJ)")
+	fieldsCmd.Flags().BoolVar(&flagFieldsAll, "all", false, "Include system fields as well as custom fields")
+}
+
+func runFields(cmd *cobra.Command, args []string) error {
+	repoPath, err := resolveRepoPath("")
+	if err != nil {
+		return err
+	}
+	conf, err := loadConfig(repoPath)
+	if err != nil {
+		return err
+	}
+
+	jiraClient, err := newJiraClient(conf)
+	if err != nil {
+		return err
+	}
+
+	fields, err := jiraClient.ListFields(flagFieldsProject)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if flagFieldsProject != "" {
+		fmt.Fprintln(w, "ID\tNAME\tTYPE\tISSUE TYPES")
+	} else {
+		fmt.Fprintln(w, "ID\tNAME\tTYPE")
+	}
+
+	for _, f := range fields {
+		if !f.Custom && !flagFieldsAll {
+			continue
+		}
+		if flagFieldsProject != "" {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.ID, f.Name, f.Type, strings.Join(f.IssueTypes, ", "))
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.ID, f.Name, f.Type)
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bsaliba1/jira-claude/internal/claude"
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	"github.com/bsaliba1/jira-claude/internal/templates"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// planTicket asks Claude, limited to read-only tools, for an implementation
+// plan, shows it to the user and asks for approval. The plan is saved to
+// --plan-file and posted to Jira with --post-plan. It returns the plan and
+// whether the user approved it.
+func planTicket(ctx context.Context, env *workEnv, dir string, data templates.TicketData, opts []claude.Option) (string, bool, error) {
+	l := log.Ctx(ctx)
+	ticket := data.Ticket
+
+	prompt, err := env.templates.Render(templates.PlanPrompt, data)
+	if err != nil {
+		return "", false, err
+	}
+
+	l.Info().Msg("asking Claude for an implementation plan (read-only)")
+	opts = append(opts, claude.WithReadOnly())
+	plan, err := newClaude(env.conf, dir, opts...).RunWithOutput(ctx, prompt)
+	if err != nil {
+		return "", false, pkgerrors.Wrap(err, "Claude Code failed to produce a plan")
+	}
+	plan = strings.TrimSpace(plan)
+	if plan == "" {
+		return "", false, fmt.Errorf("Claude returned an empty plan")
+	}
+
+	fmt.Printf("\n--- Implementation plan for %s ---\n%s\n---------------------------------\n\n", ticket.Key, plan)
+
+	if flagPlanFile != "" {
+		if err := os.WriteFile(flagPlanFile, []byte(plan+"\n"), 0o644); err != nil {
+			return "", false, pkgerrors.Wrap(err, "failed to save plan")
+		}
+		l.Info().Str("file", flagPlanFile).Msg("saved plan")
+	}
+
+	if flagPostPlan {
+		if err := env.jira.AddComment(ticket.Key, jira.FormatPlanComment(plan)); err != nil {
+			l.Warn().Err(err).Msg("failed to post plan to Jira")
+		} else {
+			l.Info().Msg("posted plan to Jira")
+		}
+	}
+
+	approved, err := confirm(os.Stdin, "Implement this plan?")
+	if err != nil {
+		return "", false, err
+	}
+	return plan, approved, nil
+}
+
+// confirm asks a yes/no question on stdout and reads the answer from in.
+// Anything but an explicit yes, including end of input, is a no.
+func confirm(in io.Reader, question string) (bool, error) {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, pkgerrors.Wrap(err, "failed to read answer")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
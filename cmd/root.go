@@ -17,6 +17,12 @@ var root = &cobra.Command{
 	Long:  `A CLI tool that takes a Jira ticket, invokes Claude Code to implement it, and creates a GitHub PR.`,
 }
 
+var flagProfile string
+
+func init() {
+	root.PersistentFlags().StringVar(&flagProfile, "profile", "", "Config profile to use (defaults to JIRA_CLAUDE_PROFILE or the config files' profile setting)")
+}
+
 func Execute() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
@@ -35,6 +41,11 @@ func Execute() {
 func wireCommands() {
 	root.AddCommand(workCmd)
 	root.AddCommand(addressPRCommentsCmd)
+	root.AddCommand(fieldsCmd)
+	root.AddCommand(templatesCmd)
+	root.AddCommand(runsCmd)
+	root.AddCommand(configCmd)
+	root.AddCommand(doctorCmd)
 }
 
 func initLogger() {
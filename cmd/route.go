@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	"github.com/bsaliba1/jira-claude/internal/repocache"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// repoRouter keeps one workEnv per repository that tickets are routed to,
+// cloning repositories given by URL into the managed cache.
+type repoRouter struct {
+	mu   sync.Mutex
+	base *workEnv
+	envs map[string]*workEnv
+}
+
+func newRepoRouter(base *workEnv) *repoRouter {
+	return &repoRouter{base: base, envs: map[string]*workEnv{base.repoPath: base}}
+}
+
+// routeTicket returns the env for the repository the ticket's project,
+// components or labels are routed to. Without a matching route, or when
+// --repo was given, env is returned unchanged. The routed env keeps env's
+// epic context.
+func routeTicket(ctx context.Context, env *workEnv, ticket *jira.Ticket) (*workEnv, error) {
+	if flagRepo != "" || env.router == nil {
+		return env, nil
+	}
+	repo := env.conf.RepoFor(ticket)
+	if repo == "" {
+		return env, nil
+	}
+
+	routed, err := env.router.envFor(ctx, repo)
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "failed to open repository for %s", ticket.Key)
+	}
+	if routed.repoPath != env.repoPath {
+		log.Ctx(ctx).Info().Str("repo", routed.repoPath).Msg("routed ticket to repository")
+	}
+	c := *routed
+	c.epicContext = env.epicContext
+	return &c, nil
+}
+
+// envFor returns the env for repo, a local path or clone URL.
+func (r *repoRouter) envFor(ctx context.Context, repo string) (*workEnv, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path, err := r.localPath(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	return r.envAtLocked(ctx, path)
+}
+
+// envAt returns the env for the repository at path.
+func (r *repoRouter) envAt(ctx context.Context, path string) (*workEnv, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.envAtLocked(ctx, path)
+}
+
+func (r *repoRouter) envAtLocked(ctx context.Context, path string) (*workEnv, error) {
+	if env, ok := r.envs[path]; ok {
+		return env, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("repository %s does not exist", path)
+	}
+
+	env, err := newWorkEnv(path, r.base.jira)
+	if err != nil {
+		return nil, err
+	}
+	env.router = r
+	if err := preflightRepo(ctx, env); err != nil {
+		return nil, err
+	}
+	r.envs[path] = env
+	return env, nil
+}
+
+// localPath resolves repo to a directory, cloning it into the cache when it
+// is a URL that has not been cloned yet.
+func (r *repoRouter) localPath(ctx context.Context, repo string) (string, error) {
+	if !repocache.IsURL(repo) {
+		return expandPath(repo)
+	}
+
+	root, err := r.base.conf.RepoCacheRoot()
+	if err != nil {
+		return "", err
+	}
+	cache := repocache.New(root)
+
+	if flagDryRun {
+		path, cloned, err := cache.Cloned(repo)
+		if err != nil {
+			return "", err
+		}
+		if !cloned {
+			return "", fmt.Errorf("%s has not been cloned yet; run without --dry-run to clone it into %s", repo, path)
+		}
+		return path, nil
+	}
+
+	return cache.Ensure(ctx, repo)
+}
+
+// expandPath resolves a leading ~ and makes path absolute.
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", pkgerrors.Wrap(err, "failed to determine home directory")
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", pkgerrors.Wrapf(err, "failed to resolve %s", path)
+	}
+	return abs, nil
+}
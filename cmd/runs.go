@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bsaliba1/jira-claude/internal/archive"
+	"github.com/bsaliba1/jira-claude/internal/config"
+	"github.com/bsaliba1/jira-claude/internal/git"
+	"github.com/bsaliba1/jira-claude/internal/verify"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// runsDir is the run archive's directory under the state root.
+const runsDir = "runs"
+
+var (
+	flagRunsLimit  int
+	flagRunsTicket string
+	flagRunsFile   string
+)
+
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Browse archived runs",
+	Long: `Every work and address-pr-comments run is archived in a directory under the
+state directory (JIRA_CLAUDE_STATE_DIR) with the rendered prompt, Claude's
+transcript, verify output, the diff, step timings and the outcome.`,
+}
+
+var runsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List archived runs, newest first",
+	Args:  cobra.NoArgs,
+	RunE:  runRunsList,
+}
+
+var runsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show an archived run's outcome, step timings and files",
+	Long: `Shows an archived run's outcome, step timings and files. With --file, prints
+one of the run's files instead, e.g. --file prompt.md or --file diff.patch.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRunsShow,
+}
+
+func init() {
+	runsListCmd.Flags().IntVarP(&flagRunsLimit, "limit", "n", 20, "Maximum number of runs to list (0 for all)")
+	runsListCmd.Flags().StringVarP(&flagRunsTicket, "ticket", "t", "", "Only list runs for this ticket")
+	runsShowCmd.Flags().StringVarP(&flagRunsFile, "file", "f", "", "Print this file from the run instead of the summary")
+
+	runsCmd.AddCommand(runsListCmd)
+	runsCmd.AddCommand(runsShowCmd)
+}
+
+// newRunStore returns the run archive under the state directory.
+func newRunStore(conf config.State) (*archive.Store, error) {
+	stateRoot, err := conf.StateRoot()
+	if err != nil {
+		return nil, err
+	}
+	return archive.NewStore(filepath.Join(stateRoot, runsDir)), nil
+}
+
+func loadRunStore() (*archive.Store, error) {
+	repoPath, err := resolveRepoPath("")
+	if err != nil {
+		return nil, err
+	}
+	conf, err := loadSettings(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	return newRunStore(conf.State)
+}
+
+func runRunsList(cmd *cobra.Command, args []string) error {
+	store, err := loadRunStore()
+	if err != nil {
+		return err
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	var shown []*archive.Run
+	for _, r := range runs {
+		if flagRunsTicket != "" && r.Ticket != flagRunsTicket {
+			continue
+		}
+		if flagRunsLimit > 0 && len(shown) == flagRunsLimit {
+			break
+		}
+		shown = append(shown, r)
+	}
+
+	if len(shown) == 0 {
+		fmt.Println("No runs archived yet.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCOMMAND\tTICKET\tSTATUS\tDURATION\tCOST\tDETAILS")
+	for _, r := range shown {
+		details := r.PRURL
+		if r.Error != "" {
+			details = r.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t$%.2f\t%s\n",
+			r.ID, r.Command, r.Ticket, r.Status, r.Duration().Round(time.Second), r.CostUSD, truncate(details, 60))
+	}
+	return w.Flush()
+}
+
+func runRunsShow(cmd *cobra.Command, args []string) error {
+	store, err := loadRunStore()
+	if err != nil {
+		return err
+	}
+
+	r, err := store.Load(args[0])
+	if errors.Is(err, archive.ErrNotFound) {
+		return fmt.Errorf("no archived run %q (see 'jira-claude runs list')", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	if flagRunsFile != "" {
+		data, err := os.ReadFile(filepath.Join(r.Dir(), filepath.Base(flagRunsFile)))
+		if err != nil {
+			return fmt.Errorf("run %s has no file %q", r.ID, flagRunsFile)
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%s\n", r.ID)
+	fmt.Fprintf(w, "Command:\t%s\n", r.Command)
+	if r.Ticket != "" {
+		fmt.Fprintf(w, "Ticket:\t%s\n", r.Ticket)
+	}
+	fmt.Fprintf(w, "Repository:\t%s\n", r.RepoPath)
+	if r.Branch != "" {
+		fmt.Fprintf(w, "Branch:\t%s\n", r.Branch)
+	}
+	fmt.Fprintf(w, "Started:\t%s\n", r.StartedAt.Format(time.RFC1123))
+	fmt.Fprintf(w, "Duration:\t%s\n", r.Duration().Round(time.Second))
+	fmt.Fprintf(w, "Status:\t%s\n", r.Status)
+	fmt.Fprintf(w, "Cost:\t$%.2f\n", r.CostUSD)
+	if r.SessionID != "" {
+		fmt.Fprintf(w, "Claude session:\t%s\n", r.SessionID)
+	}
+	if r.PRURL != "" {
+		fmt.Fprintf(w, "PR:\t%s\n", r.PRURL)
+	}
+	if r.Error != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", r.Error)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Steps) > 0 {
+		fmt.Println("\nSteps:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, step := range r.Steps {
+			fmt.Fprintf(w, "  %s\t%s\n", step.Name, step.Duration.Round(time.Millisecond))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\nFiles (in %s):\n", r.Dir())
+	for _, name := range r.Files() {
+		fmt.Printf("  %s\n", name)
+	}
+
+	return nil
+}
+
+// startArchive starts archiving a run. Archiving is best effort: on failure
+// a warning is logged and a nil run, which discards everything, is returned.
+func startArchive(ctx context.Context, store *archive.Store, command, label, repoPath string) *archive.Run {
+	if store == nil {
+		return nil
+	}
+	r, err := store.Start(command, label, repoPath)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to start run archive, this run will not be archived")
+		return nil
+	}
+	log.Ctx(ctx).Debug().Str("run", r.ID).Str("dir", r.Dir()).Msg("archiving run")
+	return r
+}
+
+// archiveFile saves content as the named file of the run.
+func archiveFile(ctx context.Context, r *archive.Run, name, content string) {
+	if err := r.WriteFile(name, []byte(content)); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to archive run file")
+	}
+}
+
+// archiveDiffOnReturn records the workspace's current commit and returns a
+// function that saves everything changed since then, committed or not, as
+// the run's diff. Deferred before the workspace is cleaned up, it archives
+// the diff of failed and interrupted runs too.
+func archiveDiffOnReturn(ctx context.Context, r *archive.Run, gitClient *git.Git) func() {
+	if r == nil {
+		return func() {}
+	}
+	start, err := gitClient.HeadCommit(ctx)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to determine starting commit, the run's diff will not be archived")
+		return func() {}
+	}
+
+	return func() {
+		ctx := context.WithoutCancel(ctx)
+		diff, err := gitClient.WorkingTreeDiff(ctx, start)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("failed to read diff for run archive")
+			return
+		}
+		if diff != "" {
+			archiveFile(ctx, r, archive.DiffFile, diff+"\n")
+		}
+	}
+}
+
+// archiveVerify appends a verify run's result to the run's verify log.
+func archiveVerify(ctx context.Context, r *archive.Run, res *verify.Result) {
+	f, err := r.Append(archive.VerifyFile)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to archive verify output")
+		return
+	}
+	defer f.Close()
+
+	status := "passed"
+	if !res.Passed {
+		status = "failed"
+	}
+	fmt.Fprintf(f, "$ %s\n%s\n[%s in %s]\n\n", res.Command, res.Output, status, res.Duration.Round(time.Millisecond))
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates [name]",
+	Short: "List the prompt, commit and PR templates or print one",
+	Long: `Lists the templates used for prompts, commit messages and PR bodies, and where
+each one is loaded from. With a name, prints that template's effective source,
+which can be copied into an override file to start from.
+
+Overrides are <name>.tmpl files in the global template directory
+(JIRA_CLAUDE_TEMPLATE_DIR) or the repository's .jira-claude/templates directory.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTemplates,
+}
+
+func init() {
+	templatesCmd.Flags().StringVarP(&flagRepo, "repo", "r", "", "Path to git repository (defaults to current directory)")
+}
+
+func runTemplates(cmd *cobra.Command, args []string) error {
+	repoPath, err := resolveRepoPath(flagRepo)
+	if err != nil {
+		return err
+	}
+
+	conf, err := loadSettings(repoPath)
+	if err != nil {
+		return err
+	}
+	tmpls, err := loadTemplates(conf.Templates, repoPath)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		text, ok := tmpls.Text(args[0])
+		if !ok {
+			return fmt.Errorf("unknown template %q", args[0])
+		}
+		fmt.Print(text)
+		return nil
+	}
+
+	sources := tmpls.Sources()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSOURCE")
+	for _, name := range tmpls.Names() {
+		fmt.Fprintf(w, "%s\t%s\n", name, sources[name])
+	}
+	return w.Flush()
+}
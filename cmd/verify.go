@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/bsaliba1/jira-claude/internal/archive"
+	"github.com/bsaliba1/jira-claude/internal/claude"
+	"github.com/bsaliba1/jira-claude/internal/session"
+	"github.com/bsaliba1/jira-claude/internal/verify"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// verifyCommand returns the command used to check Claude's changes in dir:
+// --verify, then the configured command, then one detected from the repo's
+// toolchain. It returns "" when verification is disabled or nothing fits.
+func verifyCommand(env *workEnv, dir string) string {
+	switch {
+	case flagNoVerify:
+		return ""
+	case flagVerify != "":
+		return flagVerify
+	}
+	if env.conf.VerifyCommand != "" {
+		return env.conf.VerifyCommand
+	}
+	return verify.Detect(dir)
+}
+
+// verifyChanges runs the verify command in dir and, while it fails, resumes
+// the Claude session with the failure output so Claude can fix it, up to the
+// configured number of attempts. It returns the final report (nil when there
+// is no verify command) and the cost of the extra Claude runs.
+func verifyChanges(ctx context.Context, env *workEnv, dir string, claudeClient *claude.Claude, sess *session.Session, run *archive.Run) (*verify.Report, float64, error) {
+	l := log.Ctx(ctx)
+
+	command := verifyCommand(env, dir)
+	if command == "" {
+		l.Info().Msg("no verify command configured or detected, skipping verification")
+		return nil, 0, nil
+	}
+
+	report := &verify.Report{}
+	var cost float64
+
+	for attempt := 0; ; attempt++ {
+		l.Info().Str("command", command).Int("run", attempt+1).Msg("verifying changes")
+		res, err := verify.Run(ctx, dir, command, env.conf.VerifyTimeout)
+		if err != nil {
+			return report, cost, pkgerrors.Wrap(err, "verify command failed to run")
+		}
+		report.Record(res)
+		archiveVerify(ctx, run, res)
+
+		if res.Passed {
+			l.Info().Dur("duration", res.Duration).Msg("verification passed")
+			return report, cost, nil
+		}
+
+		l.Warn().Dur("duration", res.Duration).Msg("verification failed")
+		if attempt >= env.conf.VerifyAttempts {
+			l.Warn().Int("attempts", attempt).Msg("verification still failing, giving up")
+			return report, cost, nil
+		}
+		if sess.SessionID == "" {
+			l.Warn().Msg("no Claude session to resume, cannot ask for a fix")
+			return report, cost, nil
+		}
+
+		l.Info().Int("attempt", attempt+1).Msg("asking Claude to fix verification failures")
+		fix, err := claudeClient.Resume(ctx, sess.SessionID, verify.FixPrompt(res))
+		if fix != nil {
+			cost += fix.CostUSD
+			if fix.SessionID != "" {
+				sess.SessionID = fix.SessionID
+				saveSession(ctx, env, sess)
+			}
+		}
+		reportClaudeRun(ctx, fix)
+		if err != nil {
+			return report, cost, pkgerrors.Wrap(err, "Claude Code failed while fixing verification failures")
+		}
+	}
+}
@@ -1,16 +1,22 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
+	"github.com/bsaliba1/jira-claude/internal/archive"
 	"github.com/bsaliba1/jira-claude/internal/claude"
 	"github.com/bsaliba1/jira-claude/internal/config"
+	"github.com/bsaliba1/jira-claude/internal/describe"
 	"github.com/bsaliba1/jira-claude/internal/git"
-	"github.com/bsaliba1/jira-claude/internal/github"
 	"github.com/bsaliba1/jira-claude/internal/jira"
-	"github.com/kelseyhightower/envconfig"
+	"github.com/bsaliba1/jira-claude/internal/session"
+	"github.com/bsaliba1/jira-claude/internal/templates"
+	"github.com/bsaliba1/jira-claude/internal/verify"
 	pkgerrors "github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -18,180 +24,557 @@ import (
 
 var (
 	flagTicket       string
+	flagJQL          string
 	flagRepo         string
 	flagBaseBranch   string
 	flagPromptPrefix string
 	flagDryRun       bool
+	flagWorktree     bool
+	flagParallel     int
+	flagNoAttach     bool
+	flagEpic         string
+	flagStack        bool
+	flagResume       string
+	flagVerify       string
+	flagNoVerify     bool
+	flagPlan         bool
+	flagPlanFile     string
+	flagPostPlan     bool
+	flagExistBranch  string
 )
 
 var workCmd = &cobra.Command{
 	Use:   "work",
 	Short: "Implement a Jira ticket and create a PR",
 	Long: `Fetches a Jira ticket, creates a feature branch, invokes Claude Code to implement
-the ticket, commits the changes, pushes the branch, and creates a GitHub PR.`,
+the ticket, commits the changes, pushes the branch, and creates a GitHub PR.
+
+Before committing, a verify command (--verify, the configured command, or one
+detected from the repository's toolchain) is run. If it fails, the output is
+sent back to Claude to fix, and the final result is recorded in the PR body.
+
+With --jql, every ticket matched by the query is run through the same pipeline
+one after another, and a summary of the outcomes is printed at the end.
+
+With --epic, the epic's child issues are implemented in dependency order (using
+"blocks" links), with the epic's description shared as context in every prompt.
+Add --stack to build each child's branch on top of the previous one.
+
+With --plan, Claude first explores the repository with read-only tools and
+writes an implementation plan. The plan is shown for approval, and only once
+approved does Claude implement the ticket, with the plan in its prompt.
+
+With --ticket and --resume, the ticket's existing branch is checked out and the
+Claude session from its last run is continued with the given instructions. The
+new changes are committed and pushed to the already-open PR. Sessions from
+worktree runs are resumed in a worktree recreated at the same path.
+
+With --worktree, each ticket is implemented in its own git worktree under a
+managed directory so the current checkout is left untouched. --parallel N runs
+up to N tickets at once and implies --worktree.`,
 	RunE: runWork,
 }
 
 func init() {
 	workCmd.Flags().StringVarP(&flagTicket, "ticket", "t", "", "Jira ticket key (e.g., PROJ-123)")
+	workCmd.Flags().StringVar(&flagJQL, "jql", "", "JQL query selecting the tickets to implement")
+	workCmd.Flags().StringVar(&flagEpic, "epic", "", "Epic key whose child issues should be implemented in dependency order")
+	workCmd.Flags().BoolVar(&flagStack, "stack", false, "With --epic, base each child's branch on the previous child's branch")
+	workCmd.Flags().StringVar(&flagVerify, "verify", "", "Command run after Claude finishes to check its changes (defaults to config or auto-detected)")
+	workCmd.Flags().BoolVar(&flagNoVerify, "no-verify", false, "Do not run a verify command after Claude finishes")
+	workCmd.Flags().BoolVar(&flagPlan, "plan", false, "Ask Claude for an implementation plan and wait for approval before implementing")
+	workCmd.Flags().StringVar(&flagPlanFile, "plan-file", "", "With --plan, also save the plan to this file")
+	workCmd.Flags().BoolVar(&flagPostPlan, "post-plan", false, "With --plan, also post the plan to the Jira ticket as a comment")
+	workCmd.Flags().StringVar(&flagResume, "resume", "", "Continue the ticket's last Claude session with these follow-up instructions")
+	workCmd.Flags().StringVar(&flagExistBranch, "existing-branch", "", "What to do when the ticket's branch already exists: abort, reuse, suffix or recreate (defaults to config or 'recreate')")
 	workCmd.Flags().StringVarP(&flagRepo, "repo", "r", "", "Path to git repository (defaults to current directory)")
 	workCmd.Flags().StringVarP(&flagBaseBranch, "base-branch", "b", "", "Base branch for PR (defaults to config or 'main')")
 	workCmd.Flags().StringVarP(&flagPromptPrefix, "prompt-prefix", "p", "", "Additional context to prepend to the prompt")
 	workCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print what would be done without making changes")
+	workCmd.Flags().BoolVar(&flagWorktree, "worktree", false, "Implement each ticket in an isolated git worktree")
+	workCmd.Flags().BoolVar(&flagNoAttach, "no-attachments", false, "Do not download ticket attachments for Claude")
+	workCmd.Flags().IntVar(&flagParallel, "parallel", 1, "Maximum number of tickets to implement at once (implies --worktree when > 1)")
+
+	workCmd.MarkFlagsOneRequired("ticket", "jql", "epic")
+	workCmd.MarkFlagsMutuallyExclusive("ticket", "jql", "epic")
+	workCmd.MarkFlagsMutuallyExclusive("stack", "parallel")
+	workCmd.MarkFlagsMutuallyExclusive("verify", "no-verify")
+	workCmd.MarkFlagsMutuallyExclusive("plan", "jql")
+	workCmd.MarkFlagsMutuallyExclusive("plan", "epic")
+	workCmd.MarkFlagsMutuallyExclusive("plan", "resume")
+	workCmd.MarkFlagsMutuallyExclusive("resume", "jql")
+	workCmd.MarkFlagsMutuallyExclusive("resume", "epic")
+}
+
+// workEnv holds the settings shared by every ticket processed in a single run.
+type workEnv struct {
+	conf     config.Config
+	repoPath string
+	// baseBranch and promptPrefix come from flags. An empty baseBranch means
+	// the ticket's project default, and the configured prompt prefix is used
+	// when promptPrefix is empty.
+	baseBranch   string
+	promptPrefix string
+	jira         jira.Client
+	sessions     *session.Store
+	runs         *archive.Store
+	templates    *templates.Set
+	// epicContext describes the epic being worked through with --epic. It
+	// follows the resolved prompt prefix in each ticket prompt.
+	epicContext string
+
+	useWorktrees bool
+	worktreeRoot string
+	// repoMu serializes git operations on the main repository when tickets
+	// run in parallel worktrees.
+	repoMu *sync.Mutex
+	// router hands out the env of the repository each ticket is routed to.
+	router *repoRouter
+}
+
+// forProject returns a copy of env using the project's settings.
+func (e *workEnv) forProject(projectKey string) *workEnv {
+	c := *e
+	c.conf = e.conf.ForProject(projectKey)
+	if c.promptPrefix == "" {
+		c.promptPrefix = c.conf.PromptPrefix
+	}
+	return &c
+}
 
-	workCmd.MarkFlagRequired("ticket")
+// ticketPromptPrefix returns the prompt prefix followed by the epic context,
+// if any.
+func (e *workEnv) ticketPromptPrefix() string {
+	return strings.TrimSpace(e.promptPrefix + "\n\n" + e.epicContext)
 }
 
 func runWork(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
-	l := log.Ctx(ctx).With().Str("ticket", flagTicket).Logger()
 
-	// Load configuration
-	var conf config.Config
-	if err := envconfig.Process(config.EnvConfigPrefix, &conf); err != nil {
-		return pkgerrors.Wrap(err, "failed to load configuration (check JIRA_CLAUDE_* env vars)")
+	repoPath, err := resolveRepoPath(flagRepo)
+	if err != nil {
+		return err
 	}
 
-	// Resolve repository path
-	repoPath := flagRepo
-	if repoPath == "" {
-		cwd, err := os.Getwd()
-		if err != nil {
-			return pkgerrors.Wrap(err, "failed to get current directory")
+	env, err := newWorkEnv(repoPath, nil)
+	if err != nil {
+		return err
+	}
+	env.router = newRepoRouter(env)
+
+	// When tickets may be routed elsewhere, each repository is checked as
+	// it is first routed to.
+	if err := preflight(ctx, env, flagRepo == "" && env.conf.RoutesRepos()); err != nil {
+		return err
+	}
+
+	switch {
+	case flagJQL != "":
+		return runWorkBatch(ctx, env, flagJQL)
+	case flagEpic != "":
+		return runWorkEpic(ctx, env, flagEpic)
+	case flagResume != "":
+		return runWorkResume(ctx, env, flagTicket, flagResume)
+	}
+
+	return workTicket(ctx, env, flagTicket, env.baseBranch).Err
+}
+
+// newWorkEnv loads the configuration, templates and state locations for
+// working in repoPath. A nil jiraClient is created from the configuration;
+// otherwise it is shared, so Jira settings come from the repository the
+// command was started in.
+func newWorkEnv(repoPath string, jiraClient jira.Client) (*workEnv, error) {
+	conf, err := loadConfig(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if flagExistBranch != "" {
+		if err := conf.ExistingBranch.Decode(flagExistBranch); err != nil {
+			return nil, err
 		}
-		repoPath = cwd
 	}
-	repoPath, err := filepath.Abs(repoPath)
+
+	if jiraClient == nil {
+		if jiraClient, err = newJiraClient(conf); err != nil {
+			return nil, err
+		}
+	}
+
+	stateRoot, err := conf.StateRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpls, err := loadTemplates(conf.Templates, repoPath)
 	if err != nil {
-		return pkgerrors.Wrap(err, "failed to resolve repository path")
+		return nil, err
 	}
 
-	// Resolve base branch
-	baseBranch := flagBaseBranch
-	if baseBranch == "" {
-		baseBranch = conf.DefaultBaseBranch
+	env := &workEnv{
+		conf:         conf,
+		repoPath:     repoPath,
+		baseBranch:   flagBaseBranch,
+		promptPrefix: flagPromptPrefix,
+		jira:         jiraClient,
+		sessions:     session.NewStore(filepath.Join(stateRoot, "sessions")),
+		runs:         archive.NewStore(filepath.Join(stateRoot, runsDir)),
+		templates:    tmpls,
+		useWorktrees: flagWorktree || flagParallel > 1,
+		repoMu:       &sync.Mutex{},
+	}
+
+	if env.useWorktrees {
+		env.worktreeRoot, err = conf.WorktreeRoot()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return env, nil
+}
+
+// workTicket runs the full pipeline for one ticket, branching from and
+// opening the PR against baseBranch, or the project's default base branch if
+// empty, and records how it ended.
+func workTicket(ctx context.Context, env *workEnv, ticketKey, baseBranch string) *ticketResult {
+	result := &ticketResult{Key: ticketKey, BaseBranch: baseBranch}
+	result.archive = startArchive(ctx, env.runs, "work", ticketKey, env.repoPath)
+
+	result.finish(ctx, runTicketPipeline(ctx, env, result))
+
+	result.archive.Update(func(r *archive.Run) {
+		r.Branch = result.Branch
+		r.PRURL = result.PRURL
+		r.CostUSD = result.CostUSD
+	})
+	if err := result.archive.Finish(string(result.Status), result.Err); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to archive run")
 	}
+	return result
+}
 
-	l.Info().Str("repo", repoPath).Str("baseBranch", baseBranch).Msg("starting work on ticket")
+func runTicketPipeline(ctx context.Context, env *workEnv, result *ticketResult) (err error) {
+	l := log.Ctx(ctx).With().Str("ticket", result.Key).Logger()
 
-	// Step 1: Create Jira client and fetch ticket
+	l.Info().Msg("starting work on ticket")
+
+	// Step 1: Fetch ticket
+	result.step("fetching the ticket")
 	l.Info().Msg("fetching Jira ticket")
-	jiraClient, err := jira.NewClient(conf.JiraHost, conf.JiraUsername, conf.JiraAPIToken)
+	ticket, err := env.jira.GetTicket(result.Key, env.conf.TicketOptions())
 	if err != nil {
-		return pkgerrors.Wrap(err, "failed to create Jira client")
+		return pkgerrors.Wrap(err, "failed to fetch ticket")
 	}
+	result.Summary = ticket.Summary
+
+	// Report how the run ended on the ticket, whatever the outcome. By the
+	// time this runs, env is the routed project's.
+	defer func() {
+		result.finish(ctx, err)
+		if result.Status != ticketStatusDryRun && result.Status != ticketStatusPlanRejected {
+			postRunComment(l.WithContext(ctx), env, ticket.Key, result.report())
+		}
+	}()
 
-	ticket, err := jiraClient.GetTicket(flagTicket)
+	routed, err := routeTicket(l.WithContext(ctx), env, ticket)
 	if err != nil {
-		return pkgerrors.Wrap(err, "failed to fetch ticket")
+		return err
+	}
+	env = routed
+	repoPath := env.repoPath
+	result.archive.Update(func(r *archive.Run) { r.RepoPath = repoPath })
+
+	env = env.forProject(ticket.ProjectKey)
+	conf := env.conf
+	if result.BaseBranch == "" {
+		result.BaseBranch = conf.DefaultBaseBranch
 	}
+	baseBranch := result.BaseBranch
 
 	l.Info().
 		Str("summary", ticket.Summary).
 		Str("type", ticket.IssueType).
+		Str("repo", repoPath).
+		Str("baseBranch", baseBranch).
 		Msg("fetched ticket details")
 
-	// Step 2: Initialize git and ensure clean state
-	gitClient := git.New(repoPath)
-
-	if err := gitClient.EnsureClean(); err != nil {
-		return pkgerrors.Wrap(err, "repository must be clean before starting")
+	// Step 2: Prepare a workspace on the feature branch
+	result.step("preparing the workspace")
+	ws, err := prepareWorkspace(l.WithContext(ctx), env, ticket.Key,
+		git.GenerateBranchName(conf.BranchPrefix, ticket.Key, ticket.Summary), baseBranch)
+	if err != nil {
+		return err
 	}
+	branchName := ws.branch
+	result.Branch = branchName
 
-	// Step 3: Checkout base branch and pull latest
-	l.Info().Str("branch", baseBranch).Msg("checking out base branch")
+	sess := &session.Session{
+		TicketKey:     ticket.Key,
+		TicketSummary: ticket.Summary,
+		RepoPath:      repoPath,
+		WorkDir:       ws.dir,
+		Worktree:      env.useWorktrees,
+		Branch:        branchName,
+		BaseBranch:    baseBranch,
+	}
+	defer ws.cleanup()
+	gitClient := ws.git
+	defer archiveDiffOnReturn(l.WithContext(ctx), result.archive, gitClient)()
 	if !flagDryRun {
-		if err := gitClient.Checkout(baseBranch); err != nil {
-			return pkgerrors.Wrapf(err, "failed to checkout %s", baseBranch)
+		defer func() { result.Files = changedFiles(l.WithContext(ctx), gitClient, ws.base) }()
+	}
+
+	// Step 3: Download attachments, generate prompt and invoke Claude
+	var claudeOpts []claude.Option
+	if !flagNoAttach {
+		result.step("downloading attachments")
+		attachDir, cleanupAttachments, err := downloadAttachments(l.WithContext(ctx), env, ticket)
+		if err != nil {
+			return err
 		}
-		if err := gitClient.Pull(); err != nil {
-			l.Warn().Err(err).Msg("failed to pull latest (continuing anyway)")
+		defer cleanupAttachments()
+		if attachDir != "" {
+			claudeOpts = append(claudeOpts, claude.WithAdditionalDirs(attachDir))
 		}
 	}
 
-	// Step 4: Create feature branch
-	branchName := git.GenerateBranchName(conf.BranchPrefix, ticket.Key, ticket.Summary)
-	l.Info().Str("branch", branchName).Msg("creating feature branch")
+	data := templates.TicketData{
+		Ticket:       ticket,
+		PromptPrefix: env.ticketPromptPrefix(),
+		Run: templates.Run{
+			RepoPath:   repoPath,
+			Branch:     branchName,
+			BaseBranch: baseBranch,
+			TicketURL:  fmt.Sprintf("%s/browse/%s", conf.JiraHost, ticket.Key),
+		},
+	}
+	prompt, err := env.templates.Render(templates.TicketPrompt, data)
+	if err != nil {
+		return err
+	}
 
-	if flagDryRun {
-		l.Info().Msg("[dry-run] would create branch")
-	} else {
-		if gitClient.BranchExists(branchName) {
-			l.Info().Str("branch", branchName).Msg("branch exists, deleting and recreating")
-			if err := gitClient.DeleteBranch(branchName); err != nil {
-				return pkgerrors.Wrap(err, "failed to delete existing branch")
+	if flagPlan {
+		result.step("planning")
+		if flagDryRun {
+			l.Info().Msg("[dry-run] would ask Claude for a plan and wait for approval")
+		} else {
+			plan, approved, err := planTicket(l.WithContext(ctx), env, ws.dir, data, claudeOpts)
+			if err != nil {
+				return err
+			}
+			archiveFile(l.WithContext(ctx), result.archive, archive.PlanFile, plan)
+			if !approved {
+				l.Info().Msg("plan not approved, stopping")
+				result.Status = ticketStatusPlanRejected
+				return nil
+			}
+			data.Plan = plan
+			if prompt, err = env.templates.Render(templates.TicketPrompt, data); err != nil {
+				return err
 			}
-		}
-		if err := gitClient.CreateBranch(branchName); err != nil {
-			return pkgerrors.Wrap(err, "failed to create feature branch")
 		}
 	}
 
-	// Step 5: Generate prompt and invoke Claude
-	prompt := ticket.FormatAsPrompt(flagPromptPrefix)
+	archiveFile(l.WithContext(ctx), result.archive, archive.PromptFile, prompt)
 	l.Info().Msg("invoking Claude Code")
 
+	var verification *verify.Report
 	if flagDryRun {
 		l.Info().Str("prompt", prompt).Msg("[dry-run] would invoke Claude with prompt")
+		if command := verifyCommand(env, ws.dir); command != "" {
+			l.Info().Str("command", command).Msg("[dry-run] would verify changes")
+		}
 	} else {
-		claudeClient := claude.New(repoPath)
-		if err := claudeClient.Run(prompt); err != nil {
+		transitionTicket(l.WithContext(ctx), env, ticket, conf.InProgressStatus)
+
+		if flagParallel > 1 {
+			claudeOpts = append(claudeOpts, claude.WithProgress(os.Stdout, "["+ticket.Key+"]"))
+		}
+
+		transcript, err := result.archive.Append(archive.TranscriptFile)
+		if err != nil {
+			return err
+		}
+		defer transcript.Close()
+		claudeOpts = append(claudeOpts, claude.WithTranscript(transcript))
+
+		result.step("running Claude")
+		claudeClient := newClaude(conf, ws.dir, claudeOpts...)
+		run, err := claudeClient.Run(ctx, prompt)
+		if run != nil {
+			result.CostUSD = run.CostUSD
+			sess.SessionID = run.SessionID
+			saveSession(l.WithContext(ctx), env, sess)
+			result.archive.Update(func(r *archive.Run) { r.SessionID = run.SessionID })
+		}
+		reportClaudeRun(l.WithContext(ctx), run)
+		if err != nil {
 			return pkgerrors.Wrap(err, "Claude Code failed")
 		}
+
+		result.step("verifying changes")
+		report, cost, err := verifyChanges(l.WithContext(ctx), env, ws.dir, claudeClient, sess, result.archive)
+		result.CostUSD += cost
+		if err != nil {
+			return err
+		}
+		verification = report
 	}
 
-	// Step 6: Check for changes and commit
+	// Step 4: Check for changes and commit
+	result.step("committing changes")
 	l.Info().Msg("checking for changes")
 
 	if flagDryRun {
 		l.Info().Msg("[dry-run] would commit and push changes")
 	} else {
-		hasChanges, err := gitClient.HasChanges()
+		hasChanges, err := gitClient.HasChanges(ctx)
 		if err != nil {
 			return pkgerrors.Wrap(err, "failed to check for changes")
 		}
 
 		if !hasChanges {
 			l.Warn().Msg("no changes were made by Claude")
+			result.Status = ticketStatusNoChanges
 			return nil
 		}
 
 		// Commit changes
-		commitMsg := fmt.Sprintf("%s: %s\n\nImplemented by Claude Code", ticket.Key, ticket.Summary)
-		if err := gitClient.AddAll(); err != nil {
+		data.Run.Verification = verification
+		if err := gitClient.AddAll(ctx); err != nil {
 			return pkgerrors.Wrap(err, "failed to stage changes")
 		}
-		if err := gitClient.Commit(commitMsg); err != nil {
+		if desc := describeChanges(l.WithContext(ctx), env, ws.dir, gitClient, ticket, verification); desc != nil {
+			data.Run.GeneratedCommitMessage = describe.EnsureTicketRef(desc.CommitMessage, ticket.Key)
+			data.Run.Description = desc.PRBody
+		}
+		commitMsg, err := env.templates.Render(templates.CommitMessage, data)
+		if err != nil {
+			return err
+		}
+		if err := gitClient.Commit(ctx, commitMsg); err != nil {
 			return pkgerrors.Wrap(err, "failed to commit changes")
 		}
 		l.Info().Msg("committed changes")
 
 		// Push branch
-		if err := gitClient.Push(); err != nil {
+		result.step("pushing the branch")
+		if err := gitClient.Push(ctx); err != nil {
 			return pkgerrors.Wrap(err, "failed to push branch")
 		}
 		l.Info().Msg("pushed branch to origin")
 	}
 
-	// Step 7: Create PR
+	// Step 5: Create PR
+	result.step("creating the PR")
 	l.Info().Msg("creating pull request")
 
 	if flagDryRun {
 		l.Info().Msg("[dry-run] would create PR")
+		result.Status = ticketStatusDryRun
 	} else {
-		ghClient := github.New(repoPath)
 		prTitle := fmt.Sprintf("%s: %s", ticket.Key, ticket.Summary)
-		prBody := github.FormatPRBody(ticket.Key, ticket.Summary, conf.JiraHost)
+		prURL := ws.prURL
+		if prURL != "" {
+			// The reused branch already has a PR, which the push updated.
+			l.Info().Str("url", prURL).Msg("updated existing pull request")
+			fmt.Printf("\nPR updated: %s\n", prURL)
+			result.Status = ticketStatusPRUpdated
+		} else {
+			ghClient, err := newGitHub(ctx, conf, ws.dir)
+			if err != nil {
+				return err
+			}
+			prBody, err := env.templates.Render(templates.PRBody, data)
+			if err != nil {
+				return err
+			}
 
-		prURL, err := ghClient.CreatePR(prTitle, prBody, baseBranch)
-		if err != nil {
-			return pkgerrors.Wrap(err, "failed to create PR")
+			prURL, err = ghClient.CreatePR(ctx, prTitle, prBody, branchName, baseBranch, conf.Reviewers)
+			if err != nil {
+				if prURL == "" {
+					return pkgerrors.Wrap(err, "failed to create PR")
+				}
+				// The PR is open, so it is still linked and reported like any other.
+				l.Warn().Err(err).Strs("reviewers", conf.Reviewers).Msg("created PR but failed to request reviewers")
+			}
+
+			l.Info().Str("url", prURL).Msg("created pull request")
+			fmt.Printf("\nPR created: %s\n", prURL)
+			result.Status = ticketStatusPRCreated
 		}
+		result.PRURL = prURL
+
+		sess.PRURL = prURL
+		saveSession(l.WithContext(ctx), env, sess)
 
-		l.Info().Str("url", prURL).Msg("created pull request")
-		fmt.Printf("\nPR created: %s\n", prURL)
+		if err := env.jira.LinkPullRequest(ticket.Key, prURL, prTitle); err != nil {
+			l.Warn().Err(err).Msg("failed to link PR to Jira ticket")
+		} else {
+			l.Info().Msg("linked PR to Jira ticket")
+		}
+
+		result.step("updating Jira")
+		transitionTicket(l.WithContext(ctx), env, ticket, conf.InReviewStatus)
 	}
 
 	l.Info().Msg("work complete")
 	return nil
 }
+
+// changedFiles lists the files changed in the workspace since base,
+// committed or not, for the run summary.
+func changedFiles(ctx context.Context, gitClient *git.Git, base string) []string {
+	files, err := gitClient.WorkingTreeFiles(context.WithoutCancel(ctx), base)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to list changed files")
+	}
+	return files
+}
+
+// saveSession records the ticket's Claude session so it can be resumed.
+func saveSession(ctx context.Context, env *workEnv, sess *session.Session) {
+	if sess.SessionID == "" {
+		return
+	}
+	if err := env.sessions.Save(sess); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to save Claude session")
+	}
+}
+
+// transitionTicket moves the ticket to the given workflow status. Failures
+// are only logged: a stale board should never fail an otherwise good run.
+func transitionTicket(ctx context.Context, env *workEnv, ticket *jira.Ticket, status string) {
+	l := log.Ctx(ctx)
+
+	if status == "" || strings.EqualFold(ticket.Status, status) {
+		return
+	}
+
+	if err := env.jira.TransitionTicket(ticket.Key, status); err != nil {
+		l.Warn().Err(err).Str("status", status).Msg("failed to transition ticket")
+		return
+	}
+
+	l.Info().Str("from", ticket.Status).Str("to", status).Msg("transitioned ticket")
+	ticket.Status = status
+}
+
+// postRunComment posts a summary of the run on the ticket. Like transitions,
+// a failure here is logged and does not fail the run.
+func postRunComment(ctx context.Context, env *workEnv, ticketKey string, report jira.RunReport) {
+	l := log.Ctx(ctx)
+
+	if !env.conf.PostJiraComment {
+		return
+	}
+
+	if err := env.jira.AddComment(ticketKey, jira.FormatRunComment(report)); err != nil {
+		l.Warn().Err(err).Msg("failed to post run summary to Jira")
+		return
+	}
+
+	l.Info().Msg("posted run summary to Jira")
+}
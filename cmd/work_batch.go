@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/bsaliba1/jira-claude/internal/archive"
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// ticketStatus describes how a ticket's run through the pipeline ended.
+type ticketStatus string
+
+const (
+	ticketStatusPRCreated    ticketStatus = "PR created"
+	ticketStatusPRUpdated    ticketStatus = "PR updated"
+	ticketStatusNoChanges    ticketStatus = "no changes"
+	ticketStatusDryRun       ticketStatus = "dry run"
+	ticketStatusFailed       ticketStatus = "failed"
+	ticketStatusSkipped      ticketStatus = "skipped"
+	ticketStatusPlanRejected ticketStatus = "plan rejected"
+)
+
+// ticketResult is the outcome of running the work pipeline for one ticket.
+type ticketResult struct {
+	Key        string
+	Summary    string
+	BaseBranch string
+	Branch     string
+	Status     ticketStatus
+	PRURL      string
+	CostUSD    float64
+	Err        error
+	// Files lists the paths the run changed on its branch, committed or not.
+	Files []string
+
+	// Step is the pipeline step the ticket last started, used to report
+	// where an interrupted run stopped.
+	Step string
+
+	archive *archive.Run
+}
+
+// step records that the ticket's pipeline has started the named step.
+func (r *ticketResult) step(name string) {
+	r.Step = name
+	r.archive.Step(name)
+}
+
+// finish records err, if any, as the reason the ticket failed, naming the
+// step an interrupted run stopped in. Only the first error is kept.
+func (r *ticketResult) finish(ctx context.Context, err error) {
+	if err == nil || r.Err != nil {
+		return
+	}
+	if ctx.Err() != nil {
+		err = fmt.Errorf("interrupted while %s: %w", r.Step, err)
+	}
+	r.Status = ticketStatusFailed
+	r.Err = err
+}
+
+// report describes the result for the ticket's run summary comment.
+func (r *ticketResult) report() jira.RunReport {
+	return jira.RunReport{
+		Outcome:      string(r.Status),
+		Succeeded:    r.Status == ticketStatusPRCreated || r.Status == ticketStatusPRUpdated,
+		PRURL:        r.PRURL,
+		Branch:       r.Branch,
+		FilesChanged: r.Files,
+		Err:          r.Err,
+	}
+}
+
+// runWorkBatch runs the work pipeline for every ticket matched by the JQL
+// query and prints a summary table once all of them have been processed.
+func runWorkBatch(ctx context.Context, env *workEnv, jql string) error {
+	l := log.Ctx(ctx)
+
+	l.Info().Str("jql", jql).Msg("searching Jira for tickets")
+	tickets, err := env.jira.SearchTickets(jql)
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to search tickets")
+	}
+
+	if len(tickets) == 0 {
+		l.Info().Msg("no tickets matched the query")
+		fmt.Println("No tickets matched the JQL query.")
+		return nil
+	}
+
+	l.Info().Int("count", len(tickets)).Msg("found tickets to work on")
+
+	return finishBatch(runTickets(ctx, env, tickets), len(tickets))
+}
+
+// runTickets runs the work pipeline for each ticket, up to --parallel at a
+// time, all branching from the base branch. Results keep the input order;
+// tickets that never started because of an interrupt are omitted.
+func runTickets(ctx context.Context, env *workEnv, tickets []*jira.Ticket) []*ticketResult {
+	l := log.Ctx(ctx)
+
+	parallel := max(flagParallel, 1)
+	if parallel > 1 {
+		l.Info().Int("parallel", parallel).Msg("running tickets in parallel worktrees")
+	}
+
+	slots := make([]*ticketResult, len(tickets))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+
+	for i, ticket := range tickets {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			l.Warn().Msg("interrupted, skipping remaining tickets")
+			break
+		}
+
+		l.Info().
+			Str("ticket", ticket.Key).
+			Int("index", i+1).
+			Int("total", len(tickets)).
+			Msg("processing ticket")
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			slots[i] = runBatchTicket(ctx, env, ticket, env.baseBranch)
+		}()
+	}
+	wg.Wait()
+
+	results := make([]*ticketResult, 0, len(slots))
+	for _, result := range slots {
+		if result != nil {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// runBatchTicket runs one ticket of a batch, logging rather than returning
+// its failure so the rest of the batch can continue.
+func runBatchTicket(ctx context.Context, env *workEnv, ticket *jira.Ticket, baseBranch string) *ticketResult {
+	result := workTicket(ctx, env, ticket.Key, baseBranch)
+	if result.Summary == "" {
+		result.Summary = ticket.Summary
+	}
+	if result.Err != nil {
+		log.Ctx(ctx).Error().Err(result.Err).Str("ticket", ticket.Key).Msg("ticket failed")
+	}
+	return result
+}
+
+// finishBatch prints the summary table and turns failures or an early stop
+// into the command's error.
+func finishBatch(results []*ticketResult, total int) error {
+	printWorkSummary(results)
+
+	failed, skipped := 0, 0
+	for _, result := range results {
+		switch result.Status {
+		case ticketStatusFailed:
+			failed++
+		case ticketStatusSkipped:
+			skipped++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tickets failed", failed, total)
+	}
+	if skipped > 0 || len(results) < total {
+		return fmt.Errorf("interrupted after %d of %d tickets", len(results)-skipped, total)
+	}
+
+	return nil
+}
+
+// printWorkSummary prints a table of ticket outcomes to stdout.
+func printWorkSummary(results []*ticketResult) {
+	fmt.Println("\nSummary:")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TICKET\tSTATUS\tSUMMARY\tCOST\tDETAILS")
+	var total float64
+	for _, result := range results {
+		details := result.PRURL
+		if result.Err != nil {
+			details = result.Err.Error()
+		}
+		total += result.CostUSD
+		fmt.Fprintf(w, "%s\t%s\t%s\t$%.2f\t%s\n", result.Key, result.Status, truncate(result.Summary, 50), result.CostUSD, details)
+	}
+	w.Flush()
+
+	fmt.Printf("\nTotal Claude cost: $%.2f\n", total)
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// runWorkEpic implements the epic's unfinished child issues in dependency
+// order, sharing the epic's description as context with every child.
+func runWorkEpic(ctx context.Context, env *workEnv, epicKey string) error {
+	l := log.Ctx(ctx).With().Str("epic", epicKey).Logger()
+
+	l.Info().Msg("fetching epic")
+	epic, err := env.jira.GetTicket(epicKey, env.conf.TicketOptions())
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to fetch epic")
+	}
+
+	fm, _ := env.conf.FieldMapFor(epic.ProjectKey)
+	children, err := env.jira.SearchTickets(jira.EpicChildrenJQL(epic.Key, fm.EpicLink))
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to find epic children")
+	}
+
+	if len(children) == 0 {
+		l.Info().Msg("epic has no unfinished child issues")
+		fmt.Printf("Epic %s has no unfinished child issues.\n", epic.Key)
+		return nil
+	}
+
+	ordered, err := jira.OrderByDependencies(children)
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to order epic children")
+	}
+
+	keys := make([]string, len(ordered))
+	for i, t := range ordered {
+		keys[i] = t.Key
+	}
+	l.Info().Strs("order", keys).Bool("stack", flagStack).Msg("implementing epic children")
+
+	env.epicContext = epic.FormatAsEpicContext()
+
+	if flagStack {
+		return finishBatch(runStacked(l.WithContext(ctx), env, ordered), len(ordered))
+	}
+	return finishBatch(runTickets(l.WithContext(ctx), env, ordered), len(ordered))
+}
+
+// runStacked runs the tickets one after another, cutting each branch from
+// the previous ticket's branch so the PRs stack. Once a ticket fails, the
+// rest are skipped since they would build on a broken base.
+func runStacked(ctx context.Context, env *workEnv, tickets []*jira.Ticket) []*ticketResult {
+	l := log.Ctx(ctx)
+
+	base := env.baseBranch
+	var failedKey string
+	var results []*ticketResult
+
+	for i, ticket := range tickets {
+		if ctx.Err() != nil {
+			l.Warn().Msg("interrupted, skipping remaining tickets")
+			break
+		}
+
+		if failedKey != "" {
+			results = append(results, &ticketResult{
+				Key:     ticket.Key,
+				Summary: ticket.Summary,
+				Status:  ticketStatusSkipped,
+				Err:     fmt.Errorf("skipped because %s failed", failedKey),
+			})
+			continue
+		}
+
+		l.Info().
+			Str("ticket", ticket.Key).
+			Str("baseBranch", base).
+			Int("index", i+1).
+			Int("total", len(tickets)).
+			Msg("processing ticket")
+
+		result := runBatchTicket(ctx, env, ticket, base)
+		results = append(results, result)
+
+		switch result.Status {
+		case ticketStatusPRCreated, ticketStatusPRUpdated, ticketStatusDryRun:
+			base = result.Branch
+		case ticketStatusFailed:
+			failedKey = ticket.Key
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bsaliba1/jira-claude/internal/archive"
+	"github.com/bsaliba1/jira-claude/internal/claude"
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	"github.com/bsaliba1/jira-claude/internal/session"
+	"github.com/bsaliba1/jira-claude/internal/templates"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// runWorkResume continues the ticket's last Claude session on its existing
+// branch, then commits and pushes the follow-up so it lands on the open PR.
+func runWorkResume(ctx context.Context, env *workEnv, ticketKey, message string) error {
+	result := &ticketResult{Key: ticketKey}
+	result.archive = startArchive(ctx, env.runs, "work --resume", ticketKey, env.repoPath)
+
+	if err := resumeTicket(ctx, env, result, message); err != nil {
+		result.Status = ticketStatusFailed
+		result.Err = err
+	}
+
+	result.archive.Update(func(r *archive.Run) {
+		r.Branch = result.Branch
+		r.PRURL = result.PRURL
+		r.CostUSD = result.CostUSD
+	})
+	if err := result.archive.Finish(string(result.Status), result.Err); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to archive run")
+	}
+	return result.Err
+}
+
+func resumeTicket(ctx context.Context, env *workEnv, result *ticketResult, message string) error {
+	ticketKey := result.Key
+	l := log.Ctx(ctx).With().Str("ticket", ticketKey).Logger()
+
+	result.step("loading the session")
+	sess, err := env.sessions.Load(ticketKey)
+	if errors.Is(err, session.ErrNotFound) {
+		return fmt.Errorf("no saved Claude session for %s; run work without --resume first", ticketKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.Info().
+		Str("branch", sess.Branch).
+		Str("sessionID", sess.SessionID).
+		Msg("resuming Claude session")
+
+	// Without --repo, follow the session back to the repository the ticket
+	// was routed to.
+	if flagRepo == "" && env.router != nil && sess.RepoPath != "" && sess.RepoPath != env.repoPath {
+		if env, err = env.router.envAt(ctx, sess.RepoPath); err != nil {
+			return pkgerrors.Wrap(err, "failed to open the session's repository")
+		}
+		result.archive.Update(func(r *archive.Run) { r.RepoPath = sess.RepoPath })
+	}
+
+	env = env.forProject(jira.ProjectKeyOf(ticketKey))
+	result.Summary = sess.TicketSummary
+	result.Branch = sess.Branch
+	result.PRURL = sess.PRURL
+
+	if sess.RepoPath != env.repoPath {
+		l.Warn().
+			Str("sessionRepo", sess.RepoPath).
+			Str("repo", env.repoPath).
+			Msg("session was recorded in a different repository")
+	}
+
+	if flagDryRun {
+		l.Info().Str("prompt", message).Msg("[dry-run] would check out branch and resume Claude with prompt")
+		result.Status = ticketStatusDryRun
+		return nil
+	}
+
+	ws, err := resumeWorkspace(l.WithContext(ctx), env, sess)
+	if err != nil {
+		return err
+	}
+	defer ws.cleanup()
+	gitClient := ws.git
+	defer archiveDiffOnReturn(l.WithContext(ctx), result.archive, gitClient)()
+
+	archiveFile(l.WithContext(ctx), result.archive, archive.PromptFile, message)
+	transcript, err := result.archive.Append(archive.TranscriptFile)
+	if err != nil {
+		return err
+	}
+	defer transcript.Close()
+
+	result.step("running Claude")
+	claudeClient := newClaude(env.conf, ws.dir, claude.WithTranscript(transcript))
+	run, err := claudeClient.Resume(ctx, sess.SessionID, message)
+	reportClaudeRun(l.WithContext(ctx), run)
+	if run != nil {
+		result.CostUSD = run.CostUSD
+	}
+	if run != nil && run.SessionID != "" {
+		sess.SessionID = run.SessionID
+		saveSession(l.WithContext(ctx), env, sess)
+		result.archive.Update(func(r *archive.Run) { r.SessionID = run.SessionID })
+	}
+	if err != nil {
+		return pkgerrors.Wrap(err, "Claude Code failed")
+	}
+
+	result.step("verifying changes")
+	verification, cost, err := verifyChanges(l.WithContext(ctx), env, ws.dir, claudeClient, sess, result.archive)
+	result.CostUSD += cost
+	if err != nil {
+		return err
+	}
+
+	result.step("committing changes")
+	hasChanges, err := gitClient.HasChanges(ctx)
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to check for changes")
+	}
+	if !hasChanges {
+		l.Warn().Msg("no changes were made by Claude")
+		fmt.Println("No code changes were made by Claude.")
+		result.Status = ticketStatusNoChanges
+		return nil
+	}
+
+	data := templates.TicketData{
+		Ticket: &jira.Ticket{Key: sess.TicketKey, Summary: sess.TicketSummary},
+		Run: templates.Run{
+			RepoPath:     env.repoPath,
+			Branch:       sess.Branch,
+			BaseBranch:   sess.BaseBranch,
+			TicketURL:    fmt.Sprintf("%s/browse/%s", env.conf.JiraHost, sess.TicketKey),
+			Verification: verification,
+			FollowUp:     message,
+		},
+	}
+	commitMsg, err := env.templates.Render(templates.FollowUpCommitMessage, data)
+	if err != nil {
+		return err
+	}
+	if err := gitClient.AddAll(ctx); err != nil {
+		return pkgerrors.Wrap(err, "failed to stage changes")
+	}
+	if err := gitClient.Commit(ctx, commitMsg); err != nil {
+		return pkgerrors.Wrap(err, "failed to commit changes")
+	}
+	l.Info().Msg("committed changes")
+
+	result.step("pushing the branch")
+	if err := gitClient.Push(ctx); err != nil {
+		return pkgerrors.Wrap(err, "failed to push branch")
+	}
+	l.Info().Msg("pushed branch to origin")
+
+	// The earlier run may have stopped before opening a PR
+	if sess.PRURL == "" {
+		result.step("creating the PR")
+		ghClient, err := newGitHub(ctx, env.conf, ws.dir)
+		if err != nil {
+			return err
+		}
+		prTitle := fmt.Sprintf("%s: %s", sess.TicketKey, sess.TicketSummary)
+		prBody, err := env.templates.Render(templates.PRBody, data)
+		if err != nil {
+			return err
+		}
+
+		prURL, err := ghClient.CreatePR(ctx, prTitle, prBody, sess.Branch, sess.BaseBranch, env.conf.Reviewers)
+		if err != nil {
+			if prURL == "" {
+				return pkgerrors.Wrap(err, "failed to create PR")
+			}
+			// The PR is open, so it is still linked and reported like any other.
+			l.Warn().Err(err).Strs("reviewers", env.conf.Reviewers).Msg("created PR but failed to request reviewers")
+		}
+		sess.PRURL = prURL
+		saveSession(l.WithContext(ctx), env, sess)
+		result.PRURL = prURL
+		result.Status = ticketStatusPRCreated
+
+		if err := env.jira.LinkPullRequest(sess.TicketKey, prURL, prTitle); err != nil {
+			l.Warn().Err(err).Msg("failed to link PR to Jira ticket")
+		}
+
+		fmt.Printf("\nPR created: %s\n", prURL)
+		return nil
+	}
+
+	fmt.Printf("\nPR updated: %s\n", sess.PRURL)
+	result.Status = ticketStatusPRUpdated
+	return nil
+}
@@ -0,0 +1,454 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bsaliba1/jira-claude/internal/git"
+	"github.com/bsaliba1/jira-claude/internal/session"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// workspace is the checkout a ticket is implemented in: either the user's
+// repository itself or a dedicated git worktree.
+type workspace struct {
+	dir string
+	git *git.Git
+	// branch is the feature branch checked out, which differs from the
+	// requested name when the existing branch policy picked a suffixed one.
+	branch string
+	// base is the ref the feature branch was cut from.
+	base string
+	// prURL is the open PR of a reused branch.
+	prURL   string
+	cleanup func()
+}
+
+// prepareWorkspace returns a checkout of the feature branch, cut fresh from
+// the base branch unless the existing branch policy reuses one, creating a
+// worktree for it when worktrees are enabled.
+func prepareWorkspace(ctx context.Context, env *workEnv, ticketKey, branchName, baseBranch string) (*workspace, error) {
+	if env.useWorktrees {
+		return prepareWorktree(ctx, env, ticketKey, branchName, baseBranch)
+	}
+	return prepareInPlace(ctx, env, branchName, baseBranch)
+}
+
+// prepareInPlace checks out the feature branch in the user's repository. The
+// returned workspace's cleanup puts the repository back on the branch it
+// started on, also when preparing it fails part way.
+func prepareInPlace(ctx context.Context, env *workEnv, branchName, baseBranch string) (ws *workspace, err error) {
+	l := log.Ctx(ctx)
+	gitClient := newGit(env.conf.Timeouts, env.repoPath)
+	ws = &workspace{dir: env.repoPath, git: gitClient, branch: branchName, base: baseBranch, cleanup: func() {}}
+
+	if err := gitClient.EnsureClean(ctx); err != nil {
+		return nil, pkgerrors.Wrap(err, "repository must be clean before starting")
+	}
+
+	if !flagDryRun {
+		start, saveErr := saveCheckout(ctx, gitClient)
+		if saveErr != nil {
+			return nil, saveErr
+		}
+		w := ws
+		w.cleanup = func() { restoreCheckout(ctx, gitClient, start, w.branch) }
+		defer func() {
+			if err != nil {
+				w.cleanup()
+			}
+		}()
+	}
+
+	// Checkout base branch and pull latest
+	l.Info().Str("branch", baseBranch).Msg("checking out base branch")
+	if !flagDryRun {
+		if err := gitClient.Checkout(ctx, baseBranch); err != nil {
+			return nil, pkgerrors.Wrapf(err, "failed to checkout %s", baseBranch)
+		}
+		if err := gitClient.Pull(ctx); err != nil {
+			l.Warn().Err(err).Msg("failed to pull latest (continuing anyway)")
+		}
+	}
+
+	// Create feature branch
+	l.Info().Str("branch", branchName).Msg("creating feature branch")
+	if flagDryRun {
+		l.Info().Msg("[dry-run] would create branch")
+		return ws, nil
+	}
+
+	plan, err := planBranch(ctx, env, gitClient, branchName, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+	ws.branch = plan.name
+	ws.prURL = plan.prURL
+
+	if plan.reuse {
+		if !plan.local {
+			if err := gitClient.TrackBranch(ctx, plan.name); err != nil {
+				return nil, pkgerrors.Wrap(err, "failed to track remote branch")
+			}
+		}
+		if err := gitClient.Checkout(ctx, plan.name); err != nil {
+			return nil, pkgerrors.Wrapf(err, "failed to checkout %s", plan.name)
+		}
+		pullReused(ctx, gitClient, plan)
+		return ws, nil
+	}
+
+	if plan.deleteLocal {
+		if err := gitClient.DeleteBranch(ctx, plan.name); err != nil {
+			return nil, pkgerrors.Wrap(err, "failed to delete existing branch")
+		}
+	}
+	if err := gitClient.CreateBranch(ctx, plan.name); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create feature branch")
+	}
+
+	return ws, nil
+}
+
+// pullReused brings a reused branch up to date with origin so the push at
+// the end of the run is a fast-forward.
+func pullReused(ctx context.Context, gitClient *git.Git, plan *branchPlan) {
+	if !plan.remote {
+		return
+	}
+	if err := gitClient.Pull(ctx); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to pull existing branch (continuing anyway)")
+	}
+}
+
+// checkoutState is where the user's repository was before a run moved it.
+type checkoutState struct {
+	// branch is "HEAD" when the checkout was detached.
+	branch string
+	head   string
+}
+
+func (c *checkoutState) String() string {
+	if c.branch == "HEAD" {
+		return shortHash(c.head)
+	}
+	return c.branch
+}
+
+// saveCheckout records the repository's current branch and commit.
+func saveCheckout(ctx context.Context, gitClient *git.Git) (*checkoutState, error) {
+	branch, err := gitClient.CurrentBranch(ctx)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to determine current branch")
+	}
+	head, err := gitClient.HeadCommit(ctx)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to determine current commit")
+	}
+	return &checkoutState{branch: branch, head: head}, nil
+}
+
+// restoreCheckout returns the repository to the saved checkout. Changes the
+// run left uncommitted are kept as a WIP commit on featureBranch, or stashed
+// if the repository is on some other branch, and where they went is printed.
+// It runs even after an interrupt, so it ignores ctx's cancellation.
+func restoreCheckout(ctx context.Context, gitClient *git.Git, start *checkoutState, featureBranch string) {
+	ctx = context.WithoutCancel(ctx)
+	l := log.Ctx(ctx)
+
+	current, err := gitClient.CurrentBranch(ctx)
+	if err != nil {
+		l.Warn().Err(err).Msg("failed to determine current branch, leaving checkout as is")
+		return
+	}
+
+	dirty, err := gitClient.HasChanges(ctx)
+	if err != nil {
+		l.Warn().Err(err).Msg("failed to check for uncommitted changes, leaving checkout as is")
+		return
+	}
+	if dirty {
+		if !saveLeftoverChanges(ctx, gitClient, current, featureBranch) {
+			fmt.Printf("\nUncommitted changes could not be saved; the repository was left on %s.\n", current)
+			return
+		}
+	}
+
+	if current == start.branch && current != "HEAD" {
+		return
+	}
+
+	if start.branch == "HEAD" {
+		err = gitClient.CheckoutDetached(ctx, start.head)
+	} else {
+		err = gitClient.Checkout(ctx, start.branch)
+	}
+	if err != nil {
+		l.Warn().Err(err).Str("branch", start.String()).Msg("failed to restore original checkout")
+		fmt.Printf("\nCould not switch back to %s; the repository was left on %s.\n", start, current)
+		return
+	}
+
+	l.Info().Str("branch", start.String()).Msg("restored original checkout")
+	if current == featureBranch {
+		fmt.Printf("\nSwitched back to %s. This run's work is on branch %s.\n", start, featureBranch)
+	} else {
+		fmt.Printf("\nSwitched back to %s.\n", start)
+	}
+}
+
+// saveLeftoverChanges commits the uncommitted changes as WIP when on the
+// feature branch, falling back to a stash, and reports which it did.
+func saveLeftoverChanges(ctx context.Context, gitClient *git.Git, current, featureBranch string) bool {
+	l := log.Ctx(ctx)
+
+	if current == featureBranch {
+		err := gitClient.AddAll(ctx)
+		if err == nil {
+			err = gitClient.CommitNoVerify(ctx, "WIP: uncommitted changes from an unfinished jira-claude run")
+		}
+		if err == nil {
+			head, _ := gitClient.HeadCommit(ctx)
+			l.Info().Str("branch", featureBranch).Str("commit", head).Msg("saved uncommitted changes as a WIP commit")
+			fmt.Printf("\nUncommitted changes were saved as WIP commit %s on %s (not pushed).\n", shortHash(head), featureBranch)
+			return true
+		}
+		l.Warn().Err(err).Msg("failed to save uncommitted changes as a WIP commit, stashing them instead")
+	}
+
+	if err := gitClient.Stash(ctx, "jira-claude: uncommitted changes from "+current); err != nil {
+		l.Warn().Err(err).Msg("failed to stash uncommitted changes")
+		return false
+	}
+	l.Info().Str("branch", current).Msg("stashed uncommitted changes")
+	fmt.Printf("\nUncommitted changes on %s were stashed; run 'git stash pop' on that branch to get them back.\n", current)
+	return true
+}
+
+// saveWorktreeChanges saves a worktree's uncommitted changes the way
+// restoreCheckout does, as a WIP commit on the branch it has checked out.
+// It reports false if the worktree has changes that could not be saved.
+func saveWorktreeChanges(ctx context.Context, wtGit *git.Git) bool {
+	l := log.Ctx(ctx)
+
+	dirty, err := wtGit.HasChanges(ctx)
+	if err != nil {
+		l.Warn().Err(err).Msg("failed to check worktree for uncommitted changes")
+		return false
+	}
+	if !dirty {
+		return true
+	}
+
+	branch, err := wtGit.CurrentBranch(ctx)
+	if err != nil {
+		l.Warn().Err(err).Msg("failed to determine worktree branch")
+		return false
+	}
+	return saveLeftoverChanges(ctx, wtGit, branch, branch)
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// prepareWorktree creates a linked worktree for the feature branch under the
+// managed worktree directory, leaving the user's checkout untouched. The
+// returned workspace removes the worktree on cleanup; the branch is kept.
+func prepareWorktree(ctx context.Context, env *workEnv, ticketKey, branchName, baseBranch string) (*workspace, error) {
+	l := log.Ctx(ctx)
+	repoGit := newGit(env.conf.Timeouts, env.repoPath)
+	path := filepath.Join(env.worktreeRoot, filepath.Base(env.repoPath), strings.ToLower(ticketKey))
+
+	l.Info().Str("branch", branchName).Str("worktree", path).Msg("creating worktree for feature branch")
+	if flagDryRun {
+		l.Info().Msg("[dry-run] would create worktree")
+		return &workspace{dir: env.repoPath, git: repoGit, branch: branchName, base: baseBranch, cleanup: func() {}}, nil
+	}
+
+	// Worktree bookkeeping lives in the shared .git directory, so operations
+	// on the main repository are serialized across parallel tickets.
+	env.repoMu.Lock()
+	defer env.repoMu.Unlock()
+
+	if err := repoGit.Fetch(ctx); err != nil {
+		l.Warn().Err(err).Msg("failed to fetch latest (continuing anyway)")
+	}
+
+	startPoint := baseBranch
+	if remoteBase := "origin/" + baseBranch; repoGit.BranchExists(ctx, remoteBase) {
+		startPoint = remoteBase
+	}
+
+	if err := clearStaleWorktree(ctx, repoGit, path); err != nil {
+		return nil, err
+	}
+
+	plan, err := planBranch(ctx, env, repoGit, branchName, startPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create worktree directory")
+	}
+	if plan.reuse {
+		if !plan.local {
+			if err := repoGit.TrackBranch(ctx, plan.name); err != nil {
+				return nil, pkgerrors.Wrap(err, "failed to track remote branch")
+			}
+		}
+		if err := repoGit.AddWorktreeForBranch(ctx, path, plan.name); err != nil {
+			return nil, pkgerrors.Wrap(err, "failed to create worktree")
+		}
+		pullReused(ctx, repoGit.At(path), plan)
+	} else {
+		if plan.deleteLocal {
+			if err := repoGit.DeleteBranch(ctx, plan.name); err != nil {
+				return nil, pkgerrors.Wrap(err, "failed to delete existing branch")
+			}
+		}
+		if err := repoGit.AddWorktree(ctx, path, plan.name, startPoint); err != nil {
+			return nil, pkgerrors.Wrap(err, "failed to create worktree")
+		}
+	}
+
+	return &workspace{dir: path, git: repoGit.At(path), branch: plan.name, base: startPoint, prURL: plan.prURL,
+		cleanup: worktreeCleanup(ctx, env, repoGit, path)}, nil
+}
+
+// clearStaleWorktree removes anything left at path by an earlier run,
+// keeping any changes it did not commit. The caller holds env.repoMu.
+func clearStaleWorktree(ctx context.Context, repoGit *git.Git, path string) error {
+	l := log.Ctx(ctx)
+
+	if _, err := os.Stat(path); err == nil {
+		if !saveWorktreeChanges(ctx, repoGit.At(path)) {
+			return fmt.Errorf("worktree %s from an earlier run has uncommitted changes that could not be saved; save or remove them first", path)
+		}
+		l.Info().Str("worktree", path).Msg("removing stale worktree")
+		if err := repoGit.RemoveWorktree(ctx, path); err != nil {
+			if err := os.RemoveAll(path); err != nil {
+				return pkgerrors.Wrap(err, "failed to remove stale worktree")
+			}
+		}
+	}
+	if err := repoGit.PruneWorktrees(ctx); err != nil {
+		l.Warn().Err(err).Msg("failed to prune worktrees")
+	}
+	return nil
+}
+
+// worktreeCleanup returns a function that removes the worktree at path. The
+// branch it has checked out is kept.
+func worktreeCleanup(ctx context.Context, env *workEnv, repoGit *git.Git, path string) func() {
+	l := log.Ctx(ctx)
+	return func() {
+		env.repoMu.Lock()
+		defer env.repoMu.Unlock()
+
+		// Remove the worktree even if the run was interrupted, but only once
+		// its uncommitted changes are safe, since removal discards them.
+		ctx := context.WithoutCancel(ctx)
+		if !saveWorktreeChanges(ctx, repoGit.At(path)) {
+			l.Warn().Str("worktree", path).Msg("uncommitted changes could not be saved, keeping worktree")
+			fmt.Printf("\nUncommitted changes could not be saved; the worktree was kept at %s.\n", path)
+			return
+		}
+		if err := repoGit.RemoveWorktree(ctx, path); err != nil {
+			l.Warn().Err(err).Str("worktree", path).Msg("failed to remove worktree")
+			return
+		}
+		l.Info().Str("worktree", path).Msg("removed worktree")
+	}
+}
+
+// resumeWorkspace checks out a session's branch where Claude ran before.
+// Claude Code looks sessions up by working directory, so a session that ran
+// in a worktree is resumed in a worktree recreated at the same path.
+func resumeWorkspace(ctx context.Context, env *workEnv, sess *session.Session) (*workspace, error) {
+	if sess.Worktree {
+		return resumeWorktree(ctx, env, sess)
+	}
+	return resumeInPlace(ctx, env, sess)
+}
+
+// resumeInPlace checks out the session's branch in the user's repository.
+// The returned workspace's cleanup puts the repository back on the branch it
+// started on.
+func resumeInPlace(ctx context.Context, env *workEnv, sess *session.Session) (*workspace, error) {
+	l := log.Ctx(ctx)
+	gitClient := newGit(env.conf.Timeouts, env.repoPath)
+
+	if err := gitClient.EnsureClean(ctx); err != nil {
+		return nil, pkgerrors.Wrap(err, "repository must be clean before resuming")
+	}
+
+	start, err := saveCheckout(ctx, gitClient)
+	if err != nil {
+		return nil, err
+	}
+	ws := &workspace{dir: env.repoPath, git: gitClient, branch: sess.Branch, base: sess.BaseBranch, prURL: sess.PRURL,
+		cleanup: func() { restoreCheckout(ctx, gitClient, start, sess.Branch) }}
+
+	// Check out the existing branch and pick up anything pushed since
+	if err := gitClient.Checkout(ctx, sess.Branch); err != nil {
+		ws.cleanup()
+		return nil, pkgerrors.Wrapf(err, "failed to checkout %s", sess.Branch)
+	}
+	if err := gitClient.Pull(ctx); err != nil {
+		l.Warn().Err(err).Msg("failed to pull latest (continuing anyway)")
+	}
+	return ws, nil
+}
+
+// resumeWorktree recreates the session's worktree at the path Claude ran in,
+// with the session's branch checked out. The returned workspace removes the
+// worktree again on cleanup.
+func resumeWorktree(ctx context.Context, env *workEnv, sess *session.Session) (*workspace, error) {
+	l := log.Ctx(ctx)
+	path := sess.WorkDir
+	if path == "" {
+		return nil, fmt.Errorf("the session for %s ran in a worktree whose path was not recorded, so it cannot be resumed; run work again without --resume", sess.TicketKey)
+	}
+	repoGit := newGit(env.conf.Timeouts, env.repoPath)
+
+	l.Info().Str("branch", sess.Branch).Str("worktree", path).Msg("recreating worktree for session")
+
+	env.repoMu.Lock()
+	defer env.repoMu.Unlock()
+
+	if err := repoGit.Fetch(ctx); err != nil {
+		l.Warn().Err(err).Msg("failed to fetch latest (continuing anyway)")
+	}
+	if err := clearStaleWorktree(ctx, repoGit, path); err != nil {
+		return nil, err
+	}
+
+	if !repoGit.BranchExists(ctx, "refs/heads/"+sess.Branch) {
+		if err := repoGit.TrackBranch(ctx, sess.Branch); err != nil {
+			return nil, pkgerrors.Wrapf(err, "branch %s no longer exists locally and could not be tracked from origin", sess.Branch)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create worktree directory")
+	}
+	if err := repoGit.AddWorktreeForBranch(ctx, path, sess.Branch); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to recreate worktree")
+	}
+
+	wtGit := repoGit.At(path)
+	if err := wtGit.Pull(ctx); err != nil {
+		l.Warn().Err(err).Msg("failed to pull latest (continuing anyway)")
+	}
+
+	return &workspace{dir: path, git: wtGit, branch: sess.Branch, base: sess.BaseBranch, prURL: sess.PRURL,
+		cleanup: worktreeCleanup(ctx, env, repoGit, path)}, nil
+}
@@ -4,10 +4,10 @@ go 1.25.3
 
 require (
 	github.com/andygrunwald/go-jira v1.16.0
-	github.com/kelseyhightower/envconfig v1.4.0
 	github.com/pkg/errors v0.9.1
 	github.com/rs/zerolog v1.34.0
 	github.com/spf13/cobra v1.9.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,286 @@
+// Package archive keeps a directory per work or address-pr-comments run with
+// the rendered prompt, Claude's transcript, the final diff, step timings and
+// the outcome, so bad PRs can be debugged after the fact.
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when no run has the requested ID.
+var ErrNotFound = errors.New("no such run")
+
+// Run statuses besides the ticket outcomes recorded by the caller.
+const (
+	StatusRunning = "running"
+	StatusFailed  = "failed"
+)
+
+// Files written into a run's directory.
+const (
+	MetaFile       = "run.json"
+	PromptFile     = "prompt.md"
+	PlanFile       = "plan.md"
+	TranscriptFile = "claude.jsonl"
+	VerifyFile     = "verify.log"
+	DiffFile       = "diff.patch"
+)
+
+// Step is one timed step of a run.
+type Step struct {
+	Name      string        `json:"name"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Run is the record of one run. A nil *Run discards everything written to
+// it, so callers need not check whether archiving is enabled.
+type Run struct {
+	ID        string    `json:"id"`
+	Command   string    `json:"command"`
+	Ticket    string    `json:"ticket,omitempty"`
+	RepoPath  string    `json:"repo_path"`
+	Branch    string    `json:"branch,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at,omitzero"`
+	Status    string    `json:"status"`
+	PRURL     string    `json:"pr_url,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CostUSD   float64   `json:"cost_usd"`
+	Steps     []Step    `json:"steps,omitempty"`
+
+	dir string
+	mu  sync.Mutex
+}
+
+// Store keeps runs as directories under dir.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Start creates the directory for a new run and records it as running.
+// label, usually the ticket key, is appended to the run ID for readability.
+func (s *Store) Start(command, label, repoPath string) (*Run, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create run archive directory")
+	}
+
+	now := time.Now()
+	base := now.Format("20060102-150405")
+	if label != "" {
+		base += "-" + label
+	}
+
+	// Parallel runs can start in the same second; add a suffix on collision.
+	id := base
+	for i := 2; ; i++ {
+		err := os.Mkdir(filepath.Join(s.dir, id), 0o755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, pkgerrors.Wrap(err, "failed to create run directory")
+		}
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	r := &Run{
+		ID:        id,
+		Command:   command,
+		Ticket:    label,
+		RepoPath:  repoPath,
+		StartedAt: now,
+		Status:    StatusRunning,
+		dir:       filepath.Join(s.dir, id),
+	}
+	return r, r.save()
+}
+
+// List returns every archived run, newest first.
+func (s *Store) List() ([]*Run, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to read run archive")
+	}
+
+	var runs []*Run
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		r, err := s.Load(entry.Name())
+		if err != nil {
+			continue
+		}
+		runs = append(runs, r)
+	}
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	return runs, nil
+}
+
+// Load returns the run with the given ID, or ErrNotFound.
+func (s *Store) Load(id string) (*Run, error) {
+	dir := filepath.Join(s.dir, filepath.Base(id))
+	data, err := os.ReadFile(filepath.Join(dir, MetaFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, pkgerrors.Wrapf(ErrNotFound, "run %s", id)
+	}
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "failed to read run %s", id)
+	}
+
+	r := &Run{dir: dir}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, pkgerrors.Wrapf(err, "failed to parse run %s", id)
+	}
+	return r, nil
+}
+
+// Dir returns the run's directory.
+func (r *Run) Dir() string {
+	if r == nil {
+		return ""
+	}
+	return r.dir
+}
+
+// Files returns the names of the files saved for the run.
+func (r *Run) Files() []string {
+	if r == nil {
+		return nil
+	}
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// Duration returns how long the run took, or has taken so far.
+func (r *Run) Duration() time.Duration {
+	if r.EndedAt.IsZero() {
+		return time.Since(r.StartedAt)
+	}
+	return r.EndedAt.Sub(r.StartedAt)
+}
+
+// Step ends the current step, if any, and starts timing the named one.
+func (r *Run) Step(name string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.endStep(now)
+	r.Steps = append(r.Steps, Step{Name: name, StartedAt: now})
+	_ = r.saveLocked()
+}
+
+// Update applies fn to the run's metadata and saves it.
+func (r *Run) Update(fn func(*Run)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fn(r)
+	_ = r.saveLocked()
+}
+
+// WriteFile saves data as the named file in the run's directory.
+func (r *Run) WriteFile(name string, data []byte) error {
+	if r == nil {
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, name), data, 0o644); err != nil {
+		return pkgerrors.Wrapf(err, "failed to archive %s", name)
+	}
+	return nil
+}
+
+// Append opens the named file for appending, creating it if needed. The
+// caller must close it. A nil run returns a writer that discards.
+func (r *Run) Append(name string) (io.WriteCloser, error) {
+	if r == nil {
+		return nopCloser{io.Discard}, nil
+	}
+	f, err := os.OpenFile(filepath.Join(r.dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "failed to open %s in run archive", name)
+	}
+	return f, nil
+}
+
+// Finish records the outcome of the run.
+func (r *Run) Finish(status string, runErr error) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.EndedAt = time.Now()
+	r.endStep(r.EndedAt)
+	r.Status = status
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+	return r.saveLocked()
+}
+
+func (r *Run) endStep(now time.Time) {
+	if n := len(r.Steps); n > 0 && r.Steps[n-1].Duration == 0 {
+		r.Steps[n-1].Duration = now.Sub(r.Steps[n-1].StartedAt)
+	}
+}
+
+func (r *Run) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.saveLocked()
+}
+
+func (r *Run) saveLocked() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to encode run")
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, MetaFile), data, 0o644); err != nil {
+		return pkgerrors.Wrapf(err, "failed to save run %s", r.ID)
+	}
+	return nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -1,62 +1,252 @@
 package claude
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/bsaliba1/jira-claude/internal/proc"
 	pkgerrors "github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
 type Claude struct {
-	workDir string
+	workDir   string
+	extraDirs []string
+	timeout   time.Duration
+	readOnly  bool
+
+	model        string
+	maxTurns     int
+	allowedTools []string
+
+	progressOut    io.Writer
+	progressPrefix string
+	transcript     io.Writer
 }
 
-func New(workDir string) *Claude {
-	return &Claude{workDir: workDir}
+// Option configures optional Claude behaviour.
+type Option func(*Claude)
+
+// WithAdditionalDirs grants Claude access to directories outside workDir,
+// such as a scratch directory holding ticket attachments.
+func WithAdditionalDirs(dirs ...string) Option {
+	return func(c *Claude) {
+		c.extraDirs = append(c.extraDirs, dirs...)
+	}
 }
 
-// Run executes Claude Code with the given prompt.
-// It runs claude -p "<prompt>" in the working directory.
-func (c *Claude) Run(prompt string) error {
-	cmd := exec.Command("claude", "-p", prompt, "--allowedTools", "Write,Edit,Read,Bash,Grep,Glob", "--permission-mode", "bypassPermissions")
+// WithProgress sets where the live progress view is written, prefixing each
+// line with prefix. A nil writer disables the progress view.
+func WithProgress(w io.Writer, prefix string) Option {
+	return func(c *Claude) {
+		c.progressOut = w
+		c.progressPrefix = prefix
+	}
+}
+
+// WithTranscript copies Claude's raw output to w: one stream-json event per
+// line for Run and Resume, or the plain text reply for RunWithOutput.
+func WithTranscript(w io.Writer) Option {
+	return func(c *Claude) {
+		c.transcript = w
+	}
+}
+
+// WithTimeout limits how long a single Claude run may take. Zero means no
+// limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Claude) {
+		c.timeout = timeout
+	}
+}
+
+// WithModel selects the Claude model, e.g. "opus". Empty uses the CLI's
+// default.
+func WithModel(model string) Option {
+	return func(c *Claude) {
+		c.model = model
+	}
+}
+
+// WithMaxTurns limits the number of agentic turns per run. Zero means no
+// limit.
+func WithMaxTurns(n int) Option {
+	return func(c *Claude) {
+		c.maxTurns = n
+	}
+}
+
+// DefaultTools are the tools Claude may use when implementing changes.
+var DefaultTools = []string{"Write", "Edit", "Read", "Bash", "Grep", "Glob"}
+
+// WithAllowedTools replaces DefaultTools. Read-only runs ignore it. No tools
+// keeps the default.
+func WithAllowedTools(tools ...string) Option {
+	return func(c *Claude) {
+		c.allowedTools = tools
+	}
+}
+
+// ReadOnlyTools are the tools Claude may use in a read-only run.
+var ReadOnlyTools = []string{"Read", "Grep", "Glob"}
+
+// WithReadOnly restricts Claude to ReadOnlyTools, for runs such as planning
+// that must not modify the repository.
+func WithReadOnly() Option {
+	return func(c *Claude) {
+		c.readOnly = true
+	}
+}
+
+func New(workDir string, opts ...Option) *Claude {
+	c := &Claude{workDir: workDir, progressOut: os.Stdout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// command builds the claude invocation for the given prompt.
+func (c *Claude) command(ctx context.Context, prompt string, extraArgs ...string) *exec.Cmd {
+	tools := DefaultTools
+	if len(c.allowedTools) > 0 {
+		tools = c.allowedTools
+	}
+	args := []string{"-p", prompt, "--allowedTools", strings.Join(tools, ","), "--permission-mode", "bypassPermissions"}
+	if c.readOnly {
+		args = []string{"-p", prompt, "--allowedTools", strings.Join(ReadOnlyTools, ","), "--disallowedTools", "Write,Edit,MultiEdit,NotebookEdit,Bash"}
+	}
+	if c.model != "" {
+		args = append(args, "--model", c.model)
+	}
+	if c.maxTurns > 0 {
+		args = append(args, "--max-turns", strconv.Itoa(c.maxTurns))
+	}
+	for _, dir := range c.extraDirs {
+		args = append(args, "--add-dir", dir)
+	}
+	args = append(args, extraArgs...)
+
+	cmd := proc.Command(ctx, "claude", args...)
 	cmd.Dir = c.workDir
 
 	// Pass through environment for AWS credentials (Bedrock)
 	cmd.Env = os.Environ()
 
+	return cmd
+}
+
+// Version returns the installed Claude Code version.
+func (c *Claude) Version(ctx context.Context) (string, error) {
+	ctx, cancel := proc.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	out, err := proc.Command(ctx, "claude", "--version").Output()
+	if err != nil {
+		return "", pkgerrors.Wrap(proc.Err(ctx, err), "claude --version failed")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Run executes Claude Code with the given prompt.
+// It runs claude -p "<prompt>" with stream-json output in the working
+// directory, rendering progress as events arrive, and returns a summary of
+// the run. The result is returned alongside any error when available.
+// Cancelling ctx stops Claude and everything it started.
+func (c *Claude) Run(ctx context.Context, prompt string) (*RunResult, error) {
+	return c.stream(ctx, prompt)
+}
+
+// Resume continues an earlier Claude session with a follow-up prompt.
+func (c *Claude) Resume(ctx context.Context, sessionID, prompt string) (*RunResult, error) {
+	return c.stream(ctx, prompt, "--resume", sessionID)
+}
+
+// stream runs claude with stream-json output and parses its events.
+func (c *Claude) stream(ctx context.Context, prompt string, extraArgs ...string) (*RunResult, error) {
+	ctx, cancel := proc.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	cmd := c.command(ctx, prompt, append([]string{"--output-format", "stream-json", "--verbose"}, extraArgs...)...)
+
 	var stderr bytes.Buffer
-	cmd.Stdout = os.Stdout
 	cmd.Stderr = &stderr
 
-	log.Info().Str("workDir", c.workDir).Msg("invoking Claude Code")
-	log.Debug().Str("prompt", prompt).Msg("claude prompt")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to capture claude output")
+	}
+
+	log.Ctx(ctx).Info().Str("workDir", c.workDir).Msg("invoking Claude Code")
+	log.Ctx(ctx).Debug().Str("prompt", prompt).Msg("claude prompt")
 
-	if err := cmd.Run(); err != nil {
-		return pkgerrors.Wrapf(err, "claude command failed: %s", stderr.String())
+	if err := cmd.Start(); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to start claude")
+	}
+
+	result := &RunResult{}
+	p := &progress{w: c.progressOut, prefix: c.progressPrefix, workDir: c.workDir}
+
+	// Events can be large (tool results carry file contents), so read whole
+	// lines rather than using a size-limited scanner.
+	reader := bufio.NewReader(stdout)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if c.transcript != nil {
+				c.transcript.Write(append(line, '\n'))
+			}
+			if ev, ok := parseEvent(line); ok {
+				result.apply(ev)
+				p.event(ev)
+			} else {
+				p.raw(string(line))
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return result, pkgerrors.Wrapf(proc.Err(ctx, err), "claude command failed: %s", stderr.String())
 	}
 
-	return nil
+	if result.IsError {
+		return result, fmt.Errorf("claude run ended with an error: %s", result.Result)
+	}
+
+	return result, nil
 }
 
 // RunWithOutput executes Claude Code and returns the output.
-func (c *Claude) RunWithOutput(prompt string) (string, error) {
-	cmd := exec.Command("claude", "-p", prompt, "--allowedTools", "Write,Edit,Read,Bash,Grep,Glob", "--permission-mode", "bypassPermissions")
-	cmd.Dir = c.workDir
+func (c *Claude) RunWithOutput(ctx context.Context, prompt string) (string, error) {
+	ctx, cancel := proc.WithTimeout(ctx, c.timeout)
+	defer cancel()
 
-	// Pass through environment for AWS credentials (Bedrock)
-	cmd.Env = os.Environ()
+	cmd := c.command(ctx, prompt)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	log.Info().Str("workDir", c.workDir).Msg("invoking Claude Code")
-	log.Debug().Str("prompt", prompt).Msg("claude prompt")
+	log.Ctx(ctx).Info().Str("workDir", c.workDir).Msg("invoking Claude Code")
+	log.Ctx(ctx).Debug().Str("prompt", prompt).Msg("claude prompt")
 
 	if err := cmd.Run(); err != nil {
-		return "", pkgerrors.Wrapf(err, "claude command failed: %s", stderr.String())
+		return "", pkgerrors.Wrapf(proc.Err(ctx, err), "claude command failed: %s", stderr.String())
+	}
+
+	if c.transcript != nil {
+		c.transcript.Write(stdout.Bytes())
 	}
 
 	return stdout.String(), nil
@@ -0,0 +1,115 @@
+package claude
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// progress renders a compact, one-line-per-step view of a running Claude
+// session.
+type progress struct {
+	w       io.Writer
+	prefix  string
+	workDir string
+}
+
+func (p *progress) event(ev Event) {
+	if p.w == nil {
+		return
+	}
+
+	switch ev.Type {
+	case EventSystem:
+		if ev.Subtype == "init" && ev.Model != "" {
+			p.line("·", "session started (%s)", ev.Model)
+		}
+
+	case EventAssistant:
+		if ev.Message == nil {
+			return
+		}
+		for _, block := range ev.Message.Content {
+			switch block.Type {
+			case "text":
+				if text := firstLine(block.Text); text != "" {
+					p.line("●", "%s", text)
+				}
+			case "tool_use":
+				call := ToolCall{Name: block.Name, Input: block.Input}
+				p.line(toolIcon(call), "%s %s", call.Name, p.toolTarget(call))
+			}
+		}
+
+	case EventResult:
+		status := "done"
+		if ev.IsError || ev.Subtype != "success" {
+			status = "failed (" + ev.Subtype + ")"
+		}
+		p.line("✓", "%s in %s · %d turns · $%.2f", status, formatDuration(ev.DurationMS), ev.NumTurns, ev.TotalCostUSD)
+	}
+}
+
+// raw passes through output that is not a stream-json event.
+func (p *progress) raw(line string) {
+	if p.w == nil || strings.TrimSpace(line) == "" {
+		return
+	}
+	p.line(" ", "%s", line)
+}
+
+func (p *progress) line(icon, format string, args ...any) {
+	fmt.Fprintf(p.w, "%s  %s %s\n", p.prefix, icon, truncateLine(fmt.Sprintf(format, args...), 120))
+}
+
+// toolTarget returns a short description of what a tool call acts on.
+func (p *progress) toolTarget(call ToolCall) string {
+	if path := call.FilePath(); path != "" {
+		if rel, err := filepath.Rel(p.workDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+		return path
+	}
+	for _, key := range []string{"command", "pattern", "description", "url", "query"} {
+		if s, ok := call.Input[key].(string); ok {
+			return firstLine(s)
+		}
+	}
+	return ""
+}
+
+func toolIcon(call ToolCall) string {
+	switch {
+	case call.IsEdit():
+		return "✎"
+	case call.Name == "Bash":
+		return "$"
+	default:
+		return "▸"
+	}
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " …"
+	}
+	return s
+}
+
+func truncateLine(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+func formatDuration(ms int64) string {
+	secs := ms / 1000
+	if secs < 60 {
+		return fmt.Sprintf("%ds", secs)
+	}
+	return fmt.Sprintf("%dm%02ds", secs/60, secs%60)
+}
@@ -0,0 +1,158 @@
+package claude
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Event types emitted by claude --output-format stream-json.
+const (
+	EventSystem    = "system"
+	EventAssistant = "assistant"
+	EventUser      = "user"
+	EventResult    = "result"
+)
+
+// Event is one line of Claude's stream-json output. Only the fields used by
+// this tool are decoded.
+type Event struct {
+	Type      string   `json:"type"`
+	Subtype   string   `json:"subtype"`
+	SessionID string   `json:"session_id"`
+	Model     string   `json:"model"`
+	Message   *Message `json:"message"`
+
+	// Set on the final result event.
+	Result       string  `json:"result"`
+	IsError      bool    `json:"is_error"`
+	NumTurns     int     `json:"num_turns"`
+	DurationMS   int64   `json:"duration_ms"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	Usage        *Usage  `json:"usage"`
+}
+
+// Message is an assistant or user message carried by an event.
+type Message struct {
+	Content []ContentBlock `json:"content"`
+}
+
+// ContentBlock is a single piece of a message: text, a tool call or the
+// result of a tool call.
+type ContentBlock struct {
+	Type  string         `json:"type"`
+	Text  string         `json:"text"`
+	ID    string         `json:"id"`
+	Name  string         `json:"name"`
+	Input map[string]any `json:"input"`
+
+	ToolUseID string `json:"tool_use_id"`
+	IsError   bool   `json:"is_error"`
+}
+
+// Usage reports token counts for a run.
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+}
+
+// ToolCall is a tool invocation made by Claude during a run.
+type ToolCall struct {
+	ID    string
+	Name  string
+	Input map[string]any
+}
+
+// FilePath returns the file the tool call operates on, if any.
+func (t ToolCall) FilePath() string {
+	for _, key := range []string{"file_path", "notebook_path", "path"} {
+		if p, ok := t.Input[key].(string); ok {
+			return p
+		}
+	}
+	return ""
+}
+
+// IsEdit reports whether the tool call modifies a file.
+func (t ToolCall) IsEdit() bool {
+	switch t.Name {
+	case "Edit", "MultiEdit", "Write", "NotebookEdit":
+		return true
+	}
+	return false
+}
+
+// RunResult summarizes a completed Claude run.
+type RunResult struct {
+	SessionID    string
+	Model        string
+	Result       string
+	IsError      bool
+	NumTurns     int
+	Duration     time.Duration
+	CostUSD      float64
+	InputTokens  int
+	OutputTokens int
+
+	// Messages holds the text of every assistant message, in order.
+	Messages    []string
+	ToolCalls   []ToolCall
+	FilesEdited []string
+}
+
+// parseEvent decodes a stream-json line. Lines that are not JSON objects
+// yield ok == false.
+func parseEvent(line []byte) (Event, bool) {
+	var ev Event
+	if len(line) == 0 || line[0] != '{' {
+		return ev, false
+	}
+	if err := json.Unmarshal(line, &ev); err != nil {
+		return ev, false
+	}
+	return ev, ev.Type != ""
+}
+
+// apply folds an event into the run result.
+func (r *RunResult) apply(ev Event) {
+	if ev.SessionID != "" {
+		r.SessionID = ev.SessionID
+	}
+
+	switch ev.Type {
+	case EventSystem:
+		if ev.Model != "" {
+			r.Model = ev.Model
+		}
+
+	case EventAssistant:
+		if ev.Message == nil {
+			return
+		}
+		for _, block := range ev.Message.Content {
+			switch block.Type {
+			case "text":
+				r.Messages = append(r.Messages, block.Text)
+			case "tool_use":
+				call := ToolCall{ID: block.ID, Name: block.Name, Input: block.Input}
+				r.ToolCalls = append(r.ToolCalls, call)
+				if path := call.FilePath(); call.IsEdit() && path != "" && !slices.Contains(r.FilesEdited, path) {
+					r.FilesEdited = append(r.FilesEdited, path)
+				}
+			}
+		}
+
+	case EventResult:
+		r.Result = ev.Result
+		r.IsError = ev.IsError || ev.Subtype != "success"
+		r.NumTurns = ev.NumTurns
+		r.Duration = time.Duration(ev.DurationMS) * time.Millisecond
+		r.CostUSD = ev.TotalCostUSD
+		if ev.Usage != nil {
+			r.InputTokens = ev.Usage.InputTokens + ev.Usage.CacheReadInputTokens + ev.Usage.CacheCreationInputTokens
+			r.OutputTokens = ev.Usage.OutputTokens
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package claude
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Recorded claude --output-format stream-json --verbose lines, shortened.
+const (
+	initLine      = `{"type":"system","subtype":"init","cwd":"/repo","session_id":"5f0c","tools":["Read","Edit"],"model":"claude-sonnet-4-5"}`
+	textLine      = `{"type":"assistant","message":{"id":"msg_1","role":"assistant","content":[{"type":"text","text":"I'll add the handler."}]},"session_id":"5f0c"}`
+	editLine      = `{"type":"assistant","message":{"id":"msg_2","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"Edit","input":{"file_path":"/repo/main.go","old_string":"a","new_string":"b"}}]},"session_id":"5f0c"}`
+	writeLine     = `{"type":"assistant","message":{"id":"msg_3","role":"assistant","content":[{"type":"tool_use","id":"toolu_2","name":"Write","input":{"file_path":"/repo/main_test.go","content":"package main"}},{"type":"tool_use","id":"toolu_3","name":"Edit","input":{"file_path":"/repo/main.go","old_string":"b","new_string":"c"}}]},"session_id":"5f0c"}`
+	readLine      = `{"type":"assistant","message":{"id":"msg_4","role":"assistant","content":[{"type":"tool_use","id":"toolu_4","name":"Read","input":{"file_path":"/repo/go.mod"}}]},"session_id":"5f0c"}`
+	toolResult    = `{"type":"user","message":{"role":"user","content":[{"tool_use_id":"toolu_1","type":"tool_result","content":"ok"}]},"session_id":"5f0c"}`
+	successResult = `{"type":"result","subtype":"success","is_error":false,"duration_ms":83210,"num_turns":7,"result":"Added the handler and a test.","session_id":"5f0c","total_cost_usd":0.4213,"usage":{"input_tokens":12,"cache_creation_input_tokens":3000,"cache_read_input_tokens":45000,"output_tokens":1800}}`
+	maxTurnsLine  = `{"type":"result","subtype":"error_max_turns","is_error":false,"duration_ms":120000,"num_turns":30,"session_id":"5f0c","total_cost_usd":1.5}`
+	errorResult   = `{"type":"result","subtype":"success","is_error":true,"duration_ms":1500,"num_turns":1,"result":"API Error: 529 overloaded","session_id":"5f0c","total_cost_usd":0}`
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		wantType string
+		wantOK   bool
+	}{
+		{name: "system init", line: initLine, wantType: EventSystem, wantOK: true},
+		{name: "assistant", line: editLine, wantType: EventAssistant, wantOK: true},
+		{name: "result", line: successResult, wantType: EventResult, wantOK: true},
+		{name: "empty", line: "", wantOK: false},
+		{name: "plain text", line: "Warning: something went wrong", wantOK: false},
+		{name: "truncated JSON", line: `{"type":"assistant","message":{"content":[`, wantOK: false},
+		{name: "object without type", line: `{"session_id":"5f0c"}`, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, ok := parseEvent([]byte(tt.line))
+			if ok != tt.wantOK {
+				t.Fatalf("parseEvent() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && ev.Type != tt.wantType {
+				t.Errorf("parseEvent() type = %q, want %q", ev.Type, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestRunResultApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  RunResult
+	}{
+		{
+			name:  "successful run",
+			lines: []string{initLine, textLine, editLine, toolResult, writeLine, readLine, successResult},
+			want: RunResult{
+				SessionID:    "5f0c",
+				Model:        "claude-sonnet-4-5",
+				Result:       "Added the handler and a test.",
+				NumTurns:     7,
+				Duration:     83210 * time.Millisecond,
+				CostUSD:      0.4213,
+				InputTokens:  48012,
+				OutputTokens: 1800,
+				Messages:     []string{"I'll add the handler."},
+				ToolCalls: []ToolCall{
+					{ID: "toolu_1", Name: "Edit", Input: map[string]any{"file_path": "/repo/main.go", "old_string": "a", "new_string": "b"}},
+					{ID: "toolu_2", Name: "Write", Input: map[string]any{"file_path": "/repo/main_test.go", "content": "package main"}},
+					{ID: "toolu_3", Name: "Edit", Input: map[string]any{"file_path": "/repo/main.go", "old_string": "b", "new_string": "c"}},
+					{ID: "toolu_4", Name: "Read", Input: map[string]any{"file_path": "/repo/go.mod"}},
+				},
+				FilesEdited: []string{"/repo/main.go", "/repo/main_test.go"},
+			},
+		},
+		{
+			name:  "error result",
+			lines: []string{initLine, errorResult},
+			want: RunResult{
+				SessionID: "5f0c",
+				Model:     "claude-sonnet-4-5",
+				Result:    "API Error: 529 overloaded",
+				IsError:   true,
+				NumTurns:  1,
+				Duration:  1500 * time.Millisecond,
+			},
+		},
+		{
+			name:  "non-success subtype is an error",
+			lines: []string{initLine, maxTurnsLine},
+			want: RunResult{
+				SessionID: "5f0c",
+				Model:     "claude-sonnet-4-5",
+				IsError:   true,
+				NumTurns:  30,
+				Duration:  2 * time.Minute,
+				CostUSD:   1.5,
+			},
+		},
+		{
+			name:  "malformed lines are skipped",
+			lines: []string{"not json", initLine, `{"type":"assistant","message":`, successResult},
+			want: RunResult{
+				SessionID:    "5f0c",
+				Model:        "claude-sonnet-4-5",
+				Result:       "Added the handler and a test.",
+				NumTurns:     7,
+				Duration:     83210 * time.Millisecond,
+				CostUSD:      0.4213,
+				InputTokens:  48012,
+				OutputTokens: 1800,
+			},
+		},
+		{
+			name:  "interrupted before the result",
+			lines: []string{initLine, textLine},
+			want: RunResult{
+				SessionID: "5f0c",
+				Model:     "claude-sonnet-4-5",
+				Messages:  []string{"I'll add the handler."},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got RunResult
+			for _, line := range tt.lines {
+				if ev, ok := parseEvent([]byte(line)); ok {
+					got.apply(ev)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RunResult =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolCallFilePath(t *testing.T) {
+	ev, ok := parseEvent([]byte(strings.Replace(editLine, `"file_path"`, `"notebook_path"`, 1)))
+	if !ok {
+		t.Fatal("parseEvent() failed")
+	}
+	block := ev.Message.Content[0]
+	call := ToolCall{Name: "NotebookEdit", Input: block.Input}
+	if got := call.FilePath(); got != "/repo/main.go" {
+		t.Errorf("FilePath() = %q, want /repo/main.go", got)
+	}
+	if !call.IsEdit() {
+		t.Error("IsEdit() = false for NotebookEdit")
+	}
+}
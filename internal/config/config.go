@@ -1,11 +1,512 @@
 package config
 
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	pkgerrors "github.com/pkg/errors"
+)
+
 const EnvConfigPrefix = "JIRA_CLAUDE"
 
 type Config struct {
 	JiraHost          string `envconfig:"JIRA_HOST" required:"true"`
 	JiraUsername      string `envconfig:"JIRA_USERNAME" required:"true"`
-	JiraAPIToken      string `envconfig:"JIRA_API_TOKEN" required:"true"`
+	JiraAPIToken      string `envconfig:"JIRA_API_TOKEN" required:"true" secret:"true"`
 	BranchPrefix      string `envconfig:"BRANCH_PREFIX" default:"feature/"`
 	DefaultBaseBranch string `envconfig:"DEFAULT_BASE_BRANCH" default:"main"`
+	WorktreeDir       string `envconfig:"WORKTREE_DIR"`
+	RepoCacheDir      string `envconfig:"REPO_CACHE_DIR"`
+	PostJiraComment   bool   `envconfig:"POST_JIRA_COMMENT" default:"true"`
+	DescribeChanges   bool   `envconfig:"DESCRIBE_CHANGES" default:"true"`
+
+	// ExistingBranch decides what happens when a ticket's feature branch
+	// already exists locally or on origin.
+	ExistingBranch ExistingBranchPolicy `envconfig:"EXISTING_BRANCH" default:"recreate"`
+
+	// Extra ticket context included in the prompt. ContextMaxChars caps the
+	// combined text of comments, subtasks and linked issues.
+	IncludeComments bool `envconfig:"INCLUDE_COMMENTS" default:"true"`
+	IncludeSubtasks bool `envconfig:"INCLUDE_SUBTASKS" default:"true"`
+	IncludeLinks    bool `envconfig:"INCLUDE_LINKS" default:"true"`
+	ContextMaxChars int  `envconfig:"CONTEXT_MAX_CHARS" default:"20000"`
+
+	// FieldMap is a JSON object mapping project keys (or "*" for all
+	// projects) to the custom fields holding well-known ticket data, e.g.
+	// {"SUI":{"acceptance_criteria":"customfield_10100","story_points":"customfield_10016"}}.
+	//
+	// Deprecated: set "fields" under projects instead. Load folds each entry
+	// into the project's settings unless the project sets its own.
+	FieldMap FieldMaps `envconfig:"FIELD_MAP"`
+
+	// Attachments larger than AttachmentMaxBytes, or whose MIME type does not
+	// start with one of AttachmentTypes, are not downloaded for Claude.
+	AttachmentMaxBytes int64    `envconfig:"ATTACHMENT_MAX_BYTES" default:"10485760"`
+	AttachmentTypes    []string `envconfig:"ATTACHMENT_TYPES" default:"image/,text/,application/json,application/pdf,application/xml,application/yaml,application/x-yaml"`
+
+	// Workflow statuses the ticket is moved to while it is worked on. Set a
+	// status to an empty string to skip that transition. Projects can
+	// override them.
+	InProgressStatus string `envconfig:"IN_PROGRESS_STATUS" default:"In Progress"`
+	InReviewStatus   string `envconfig:"IN_REVIEW_STATUS" default:"In Review"`
+
+	// Per-project statuses, e.g. "SUI:Doing,PAY:In Development".
+	//
+	// Deprecated: set "in_progress_status" and "in_review_status" under
+	// projects instead. Load folds each entry into the project's settings
+	// unless the project sets its own.
+	ProjectInProgressStatuses map[string]string `envconfig:"PROJECT_IN_PROGRESS_STATUSES"`
+	ProjectInReviewStatuses   map[string]string `envconfig:"PROJECT_IN_REVIEW_STATUSES"`
+
+	// VerifyCommand is run after Claude finishes, and failures are sent back
+	// to Claude up to VerifyAttempts times. A repository sets its own in its
+	// .jira-claude.yaml. When it is not set, the command is detected from the
+	// repository's toolchain.
+	VerifyCommand  string `envconfig:"VERIFY_COMMAND"`
+	VerifyAttempts int    `envconfig:"VERIFY_ATTEMPTS" default:"2"`
+
+	// PromptPrefix is added to every ticket prompt, and Reviewers are
+	// requested on every PR. Both can be set per project.
+	PromptPrefix string   `envconfig:"PROMPT_PREFIX"`
+	Reviewers    []string `envconfig:"REVIEWERS"`
+
+	// Projects overrides settings per Jira project key, with "*" applying to
+	// every project. It is usually set in a config file; from the
+	// environment it is decoded from JSON.
+	Projects Projects `envconfig:"PROJECTS"`
+
+	Timeouts
+	GitHub
+	Templates
+	State
+	Claude
+}
+
+// Claude holds options passed to the Claude Code CLI. Empty values leave
+// the CLI's defaults in place.
+type Claude struct {
+	ClaudeModel        string   `envconfig:"CLAUDE_MODEL"`
+	ClaudeMaxTurns     int      `envconfig:"CLAUDE_MAX_TURNS"`
+	ClaudeAllowedTools []string `envconfig:"CLAUDE_ALLOWED_TOOLS"`
+}
+
+// State locates persistent state such as saved Claude sessions and the run
+// archive.
+type State struct {
+	StateDir string `envconfig:"STATE_DIR"`
+}
+
+// Templates locates the global template overrides. TemplateDir defaults to
+// a jira-claude/templates directory in the user config dir.
+type Templates struct {
+	TemplateDir string `envconfig:"TEMPLATE_DIR"`
+}
+
+// TemplateRoot returns the directory holding global template overrides.
+func (t Templates) TemplateRoot() (string, error) {
+	if t.TemplateDir != "" {
+		return t.TemplateDir, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to determine user config directory")
+	}
+
+	return filepath.Join(configDir, "jira-claude", "templates"), nil
+}
+
+// Timeouts bound how long each external command may run, e.g. "30m" or
+// "90s". Zero disables the limit.
+type Timeouts struct {
+	ClaudeTimeout time.Duration `envconfig:"CLAUDE_TIMEOUT" default:"30m"`
+	GitTimeout    time.Duration `envconfig:"GIT_TIMEOUT" default:"2m"`
+	GitHubTimeout time.Duration `envconfig:"GITHUB_TIMEOUT" default:"2m"`
+	VerifyTimeout time.Duration `envconfig:"VERIFY_TIMEOUT" default:"10m"`
+}
+
+// GitHub selects how GitHub is reached. The REST client authenticates with
+// GitHubToken, or else the token gh is logged in with, and GitHubAPIURL
+// points it at a GitHub Enterprise server instead of the one derived from
+// the origin remote.
+type GitHub struct {
+	GitHubClient GitHubClientMode `envconfig:"GITHUB_CLIENT" default:"auto"`
+	GitHubToken  string           `envconfig:"GITHUB_TOKEN" secret:"true"`
+	GitHubAPIURL string           `envconfig:"GITHUB_API_URL"`
+}
+
+// GitHubClientMode says which GitHub client is used.
+type GitHubClientMode string
+
+const (
+	// GitHubClientAuto uses the REST API when a token is available and the
+	// gh CLI otherwise.
+	GitHubClientAuto GitHubClientMode = "auto"
+	// GitHubClientAPI always uses the REST API.
+	GitHubClientAPI GitHubClientMode = "api"
+	// GitHubClientGH always runs the gh CLI.
+	GitHubClientGH GitHubClientMode = "gh"
+)
+
+// Decode parses and validates a client mode.
+func (m *GitHubClientMode) Decode(value string) error {
+	switch mode := GitHubClientMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case GitHubClientAuto, GitHubClientAPI, GitHubClientGH:
+		*m = mode
+		return nil
+	}
+	return fmt.Errorf("invalid GitHub client %q (want auto, api or gh)", value)
+}
+
+// TicketOptions returns the options for fetching a ticket with the
+// configured amount of extra context.
+func (c Config) TicketOptions() *jira.GetTicketOptions {
+	return &jira.GetTicketOptions{
+		IncludeComments: c.IncludeComments,
+		IncludeSubtasks: c.IncludeSubtasks,
+		IncludeLinks:    c.IncludeLinks,
+		MaxContextChars: c.ContextMaxChars,
+		FieldMaps:       c.fieldMaps(),
+	}
+}
+
+// fieldMaps collects the projects' custom field mappings by project key.
+// The ticket's project is not known until it is fetched, so the Jira client
+// picks the mapping, falling back to the "*" entry.
+func (c Config) fieldMaps() map[string]jira.FieldMap {
+	maps := map[string]jira.FieldMap{}
+	for key, p := range c.Projects {
+		if p.Fields != nil {
+			maps[key] = *p.Fields
+		}
+	}
+	return maps
+}
+
+// FieldMapFor returns the custom field mapping for a project, falling back
+// to the "*" project.
+func (c Config) FieldMapFor(projectKey string) (jira.FieldMap, bool) {
+	p := c.project(projectKey)
+	if p.Fields == nil {
+		return jira.FieldMap{}, false
+	}
+	return *p.Fields, true
+}
+
+// AttachmentAllowed reports whether an attachment of the given MIME type and
+// size may be downloaded.
+func (c Config) AttachmentAllowed(mimeType string, size int64) bool {
+	if c.AttachmentMaxBytes > 0 && size > c.AttachmentMaxBytes {
+		return false
+	}
+	for _, prefix := range c.AttachmentTypes {
+		if strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExistingBranchPolicy says what work does when the feature branch it would
+// create already exists. None of the policies discard commits.
+type ExistingBranchPolicy string
+
+const (
+	// ExistingBranchAbort fails the ticket.
+	ExistingBranchAbort ExistingBranchPolicy = "abort"
+	// ExistingBranchReuse checks out the existing branch and continues on
+	// top of it, updating its open PR if there is one.
+	ExistingBranchReuse ExistingBranchPolicy = "reuse"
+	// ExistingBranchSuffix creates the first free branch name with a -2,
+	// -3, ... suffix.
+	ExistingBranchSuffix ExistingBranchPolicy = "suffix"
+	// ExistingBranchRecreate deletes and recreates the branch from base, but
+	// only if it has no commits beyond base and no open PR; otherwise it
+	// aborts.
+	ExistingBranchRecreate ExistingBranchPolicy = "recreate"
+)
+
+// Decode parses and validates a policy name.
+func (p *ExistingBranchPolicy) Decode(value string) error {
+	switch policy := ExistingBranchPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case ExistingBranchAbort, ExistingBranchReuse, ExistingBranchSuffix, ExistingBranchRecreate:
+		*p = policy
+		return nil
+	}
+	return fmt.Errorf("invalid existing branch policy %q (want abort, reuse, suffix or recreate)", value)
+}
+
+// AllProjects is the project key whose settings apply to every project.
+const AllProjects = "*"
+
+// project returns the project's settings on top of those for AllProjects.
+func (c Config) project(projectKey string) Project {
+	return c.Projects.merge(Projects{AllProjects: c.Projects[projectKey]})[AllProjects]
+}
+
+// ForProject returns the configuration with the project's settings, if
+// any, in place of the global ones.
+func (c Config) ForProject(projectKey string) Config {
+	p := c.project(projectKey)
+
+	if p.BaseBranch != "" {
+		c.DefaultBaseBranch = p.BaseBranch
+	}
+	if p.BranchPrefix != "" {
+		c.BranchPrefix = p.BranchPrefix
+	}
+	if p.VerifyCommand != "" {
+		c.VerifyCommand = p.VerifyCommand
+	}
+	if p.PromptPrefix != "" {
+		c.PromptPrefix = p.PromptPrefix
+	}
+	if p.Reviewers != nil {
+		c.Reviewers = p.Reviewers
+	}
+	if p.Claude.Model != "" {
+		c.ClaudeModel = p.Claude.Model
+	}
+	if p.Claude.MaxTurns != 0 {
+		c.ClaudeMaxTurns = p.Claude.MaxTurns
+	}
+	if p.Claude.AllowedTools != nil {
+		c.ClaudeAllowedTools = p.Claude.AllowedTools
+	}
+	if p.InProgressStatus != nil {
+		c.InProgressStatus = *p.InProgressStatus
+	}
+	if p.InReviewStatus != nil {
+		c.InReviewStatus = *p.InReviewStatus
+	}
+	return c
+}
+
+// Project holds the settings that can differ per Jira project.
+type Project struct {
+	// Repo is the local path or clone URL of the repository the project's
+	// tickets are implemented in. Repos routes tickets with particular
+	// components or labels elsewhere; the first matching route wins.
+	Repo  string      `yaml:"repo" json:"repo"`
+	Repos []RepoRoute `yaml:"repos" json:"repos"`
+
+	BaseBranch    string        `yaml:"base_branch" json:"base_branch"`
+	BranchPrefix  string        `yaml:"branch_prefix" json:"branch_prefix"`
+	VerifyCommand string        `yaml:"verify_command" json:"verify_command"`
+	PromptPrefix  string        `yaml:"prompt_prefix" json:"prompt_prefix"`
+	Reviewers     []string      `yaml:"reviewers" json:"reviewers"`
+	Claude        ProjectClaude `yaml:"claude" json:"claude"`
+
+	// The workflow statuses are pointers so that an empty string, which
+	// skips the transition, can be told apart from no override.
+	InProgressStatus *string `yaml:"in_progress_status" json:"in_progress_status"`
+	InReviewStatus   *string `yaml:"in_review_status" json:"in_review_status"`
+
+	// Fields names the project's custom fields.
+	Fields *jira.FieldMap `yaml:"fields" json:"fields"`
+}
+
+// RepoRoute sends tickets with the given component and/or label to Repo.
+// When both are set, a ticket must have both.
+type RepoRoute struct {
+	Component string `yaml:"component" json:"component"`
+	Label     string `yaml:"label" json:"label"`
+	Repo      string `yaml:"repo" json:"repo"`
+}
+
+func (r RepoRoute) String() string {
+	var match []string
+	if r.Component != "" {
+		match = append(match, "component="+r.Component)
+	}
+	if r.Label != "" {
+		match = append(match, "label="+r.Label)
+	}
+	return strings.Join(match, "+") + " -> " + r.Repo
+}
+
+// matches reports whether the ticket has the route's component and label.
+func (r RepoRoute) matches(ticket *jira.Ticket) bool {
+	if r.Component == "" && r.Label == "" {
+		return false
+	}
+	if r.Component != "" && !containsFold(ticket.Components, r.Component) {
+		return false
+	}
+	if r.Label != "" && !containsFold(ticket.Labels, r.Label) {
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, want string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoutesRepos reports whether any project is routed to a repository.
+func (c Config) RoutesRepos() bool {
+	for _, p := range c.Projects {
+		if p.Repo != "" || len(p.Repos) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RepoFor returns the local path or clone URL configured for the ticket's
+// project, component or labels, or "" if the ticket is not routed.
+func (c Config) RepoFor(ticket *jira.Ticket) string {
+	p := c.Projects[ticket.ProjectKey]
+	for _, route := range p.Repos {
+		if route.matches(ticket) {
+			return route.Repo
+		}
+	}
+	return p.Repo
+}
+
+// ProjectClaude holds a project's Claude Code options.
+type ProjectClaude struct {
+	Model        string   `yaml:"model" json:"model"`
+	MaxTurns     int      `yaml:"max_turns" json:"max_turns"`
+	AllowedTools []string `yaml:"allowed_tools" json:"allowed_tools"`
+}
+
+// projectField is one setting of a Project, keyed as in config files.
+type projectField struct {
+	key   string
+	value reflect.Value
+}
+
+// fields lists the project's settings, with Claude options as
+// "claude.model" and so on.
+func (p *Project) fields() []projectField {
+	var fields []projectField
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			key := prefix + v.Type().Field(i).Tag.Get("yaml")
+			if v.Field(i).Kind() == reflect.Struct {
+				walk(key+".", v.Field(i))
+				continue
+			}
+			fields = append(fields, projectField{key: key, value: v.Field(i)})
+		}
+	}
+	walk("", reflect.ValueOf(p).Elem())
+	return fields
+}
+
+// Values returns the project's settings by key, e.g. "claude.model".
+func (p Project) Values() map[string]any {
+	values := map[string]any{}
+	for _, field := range p.fields() {
+		values[field.key] = field.value.Interface()
+	}
+	return values
+}
+
+// Projects maps Jira project keys to their settings. It is decoded from
+// JSON when loaded from the environment.
+type Projects map[string]Project
+
+// Decode parses the JSON form used in the environment.
+func (m *Projects) Decode(value string) error {
+	if err := json.Unmarshal([]byte(value), m); err != nil {
+		return pkgerrors.Wrap(err, "invalid projects JSON")
+	}
+	return nil
+}
+
+// merge returns m with the settings set in over applied on top.
+func (m Projects) merge(over Projects) Projects {
+	merged := Projects{}
+	for key, p := range m {
+		merged[key] = p
+	}
+	for key, p := range over {
+		base := merged[key]
+		baseFields := base.fields()
+		for i, field := range p.fields() {
+			if !field.value.IsZero() {
+				baseFields[i].value.Set(field.value)
+			}
+		}
+		merged[key] = base
+	}
+	return merged
+}
+
+// FieldMaps maps Jira project keys to their custom field mapping. It is
+// decoded from JSON when loaded from the environment.
+type FieldMaps map[string]jira.FieldMap
+
+// Decode parses the JSON form used in the environment.
+func (m *FieldMaps) Decode(value string) error {
+	if err := json.Unmarshal([]byte(value), m); err != nil {
+		return pkgerrors.Wrap(err, "invalid field map JSON")
+	}
+	return nil
+}
+
+// StateRoot returns the directory holding persistent state such as saved
+// Claude sessions and the run archive. It defaults to
+// $XDG_STATE_HOME/jira-claude, or ~/.local/state/jira-claude when
+// XDG_STATE_HOME is unset.
+func (s State) StateRoot() (string, error) {
+	if s.StateDir != "" {
+		return s.StateDir, nil
+	}
+	if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "jira-claude"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to determine home directory")
+	}
+
+	return filepath.Join(home, ".local", "state", "jira-claude"), nil
+}
+
+// RepoCacheRoot returns the directory repositories routed to by clone URL
+// are cloned into. It defaults to a jira-claude directory in the user cache
+// dir.
+func (c Config) RepoCacheRoot() (string, error) {
+	if c.RepoCacheDir != "" {
+		return c.RepoCacheDir, nil
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to determine user cache directory")
+	}
+
+	return filepath.Join(cacheDir, "jira-claude", "repos"), nil
+}
+
+// WorktreeRoot returns the directory under which per-ticket worktrees are
+// created. It defaults to a jira-claude directory in the user cache dir.
+func (c Config) WorktreeRoot() (string, error) {
+	if c.WorktreeDir != "" {
+		return c.WorktreeDir, nil
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to determine user cache directory")
+	}
+
+	return filepath.Join(cacheDir, "jira-claude", "worktrees"), nil
 }
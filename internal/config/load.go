@@ -0,0 +1,485 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// RepoFile is the repository-local config file, at the repository root.
+const RepoFile = ".jira-claude.yaml"
+
+// Sources of a setting, besides the config files themselves.
+const (
+	SourceDefault = "default"
+	SourceUnset   = "unset"
+)
+
+// LoadOptions says where to look for configuration.
+type LoadOptions struct {
+	// RepoPath is the repository whose RepoFile is loaded, if any.
+	RepoPath string
+	// Profile selects a named profile. When empty, JIRA_CLAUDE_PROFILE or
+	// the profile key of the repo or global file is used.
+	Profile string
+}
+
+// Loaded is the effective configuration and where each value came from.
+type Loaded struct {
+	Config Config
+	// Profile is the profile that was applied, if any.
+	Profile string
+	// Files lists the config files that were read, global file first.
+	Files []string
+	// Sources maps each setting's key, such as "verify_command" or
+	// "projects.SUI.base_branch", to the layer that set it.
+	Sources map[string]string
+}
+
+// Setting is one configurable value of Config.
+type Setting struct {
+	// Key is the name used in config files, e.g. "verify_command".
+	Key string
+	// Env is the environment variable, e.g. "JIRA_CLAUDE_VERIFY_COMMAND".
+	Env      string
+	Default  string
+	Required bool
+	Secret   bool
+
+	value reflect.Value
+}
+
+// Value returns the setting's current value.
+func (s Setting) Value() any {
+	return s.value.Interface()
+}
+
+// Settings lists c's settings in declaration order. Keys and environment
+// variables are derived from each field's envconfig tag.
+func (c *Config) Settings() []Setting {
+	return collectSettings(reflect.ValueOf(c).Elem())
+}
+
+func collectSettings(v reflect.Value) []Setting {
+	var settings []Setting
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			settings = append(settings, collectSettings(v.Field(i))...)
+			continue
+		}
+		name := field.Tag.Get("envconfig")
+		if name == "" || !field.IsExported() {
+			continue
+		}
+		settings = append(settings, Setting{
+			Key:      strings.ToLower(name),
+			Env:      EnvConfigPrefix + "_" + name,
+			Default:  field.Tag.Get("default"),
+			Required: field.Tag.Get("required") == "true",
+			Secret:   field.Tag.Get("secret") == "true",
+			value:    v.Field(i),
+		})
+	}
+	return settings
+}
+
+// GlobalFile returns the path of the global config file,
+// jira-claude/config.yaml in the user config dir.
+func GlobalFile() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to determine user config directory")
+	}
+	return filepath.Join(configDir, "jira-claude", "config.yaml"), nil
+}
+
+// configFile is a parsed config file: top-level settings plus named
+// profiles holding more settings.
+type configFile struct {
+	path     string
+	label    string
+	settings map[string]yaml.Node
+	profile  string
+	profiles map[string]map[string]yaml.Node
+}
+
+// Load builds the configuration in layers, each overriding the one before:
+// built-in defaults, the global config file, the repository's RepoFile, the
+// selected profile (from the global file, then the repo file) and finally
+// JIRA_CLAUDE_* environment variables. Required settings are not checked;
+// see Validate.
+func Load(opts LoadOptions) (*Loaded, error) {
+	loaded := &Loaded{Sources: map[string]string{}}
+	conf := &loaded.Config
+	settings := conf.Settings()
+
+	for _, s := range settings {
+		if s.Default == "" {
+			loaded.Sources[s.Key] = SourceUnset
+			continue
+		}
+		if err := decodeString(s.value, s.Default); err != nil {
+			return nil, pkgerrors.Wrapf(err, "invalid default for %s", s.Key)
+		}
+		loaded.Sources[s.Key] = SourceDefault
+	}
+
+	globalPath, err := GlobalFile()
+	if err != nil {
+		return nil, err
+	}
+	var files []*configFile
+	for _, f := range []struct{ path, label string }{
+		{globalPath, "global"},
+		{filepath.Join(opts.RepoPath, RepoFile), "repo"},
+	} {
+		if f.label == "repo" && opts.RepoPath == "" {
+			continue
+		}
+		file, err := readConfigFile(f.path, f.label)
+		if err != nil {
+			return nil, err
+		}
+		if file != nil {
+			files = append(files, file)
+			loaded.Files = append(loaded.Files, file.path)
+		}
+	}
+
+	for _, file := range files {
+		if err := loaded.apply(settings, file.settings, file.label); err != nil {
+			return nil, pkgerrors.Wrapf(err, "invalid config file %s", file.path)
+		}
+	}
+
+	loaded.Profile = selectProfile(opts.Profile, files)
+	if loaded.Profile != "" {
+		found := false
+		for _, file := range files {
+			profile, ok := file.profiles[loaded.Profile]
+			if !ok {
+				continue
+			}
+			found = true
+			label := fmt.Sprintf("profile %s (%s)", loaded.Profile, file.label)
+			if err := loaded.apply(settings, profile, label); err != nil {
+				return nil, pkgerrors.Wrapf(err, "invalid profile %q in %s", loaded.Profile, file.path)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("profile %q is not defined in any config file", loaded.Profile)
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.Env)
+		if !ok {
+			continue
+		}
+		source := "env " + s.Env
+		// Like the file layers, the environment's projects are merged in
+		// rather than replacing the ones from the files.
+		if s.Key == projectsKey {
+			var projects Projects
+			if err := projects.Decode(value); err != nil {
+				return nil, pkgerrors.Wrapf(err, "invalid %s", s.Env)
+			}
+			conf.Projects = conf.Projects.merge(projects)
+			loaded.trackProjects(projects, source)
+			loaded.Sources[s.Key] = source
+			continue
+		}
+		s.value.Set(reflect.Zero(s.value.Type()))
+		if err := decodeString(s.value, value); err != nil {
+			return nil, pkgerrors.Wrapf(err, "invalid %s", s.Env)
+		}
+		loaded.Sources[s.Key] = source
+	}
+
+	loaded.foldDeprecated()
+
+	return loaded, nil
+}
+
+// Validate reports required settings that are still empty.
+func (c *Config) Validate() error {
+	var missing []string
+	for _, s := range c.Settings() {
+		if s.Required && s.value.IsZero() {
+			missing = append(missing, fmt.Sprintf("%s (%s)", s.Env, s.Key))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+const (
+	projectsKey = "projects"
+	profileKey  = "profile"
+	profilesKey = "profiles"
+)
+
+// selectProfile picks the profile named by the flag, the environment, the
+// repo file or the global file, in that order.
+func selectProfile(flag string, files []*configFile) string {
+	if flag != "" {
+		return flag
+	}
+	if env := os.Getenv(EnvConfigPrefix + "_PROFILE"); env != "" {
+		return env
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if files[i].profile != "" {
+			return files[i].profile
+		}
+	}
+	return ""
+}
+
+// readConfigFile parses the config file at path, returning nil if it does
+// not exist.
+func readConfigFile(path, label string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "failed to read config file %s", path)
+	}
+
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, pkgerrors.Wrapf(err, "failed to parse config file %s", path)
+	}
+
+	file := &configFile{path: path, label: label, settings: map[string]yaml.Node{}}
+	for key, node := range raw {
+		switch key {
+		case profileKey:
+			if err := node.Decode(&file.profile); err != nil {
+				return nil, pkgerrors.Wrapf(err, "invalid profile in %s", path)
+			}
+		case profilesKey:
+			if err := node.Decode(&file.profiles); err != nil {
+				return nil, pkgerrors.Wrapf(err, "invalid profiles in %s", path)
+			}
+		default:
+			file.settings[key] = node
+		}
+	}
+	return file, nil
+}
+
+// apply sets every setting present in values, recording source as where it
+// came from. Projects are merged field by field rather than replaced.
+func (l *Loaded) apply(settings []Setting, values map[string]yaml.Node, source string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		node := values[key]
+		if key == projectsKey {
+			var projects Projects
+			if err := node.Decode(&projects); err != nil {
+				return pkgerrors.Wrap(err, "invalid projects")
+			}
+			l.Config.Projects = l.Config.Projects.merge(projects)
+			l.trackProjects(projects, source)
+			continue
+		}
+
+		s, ok := findSetting(settings, key)
+		if !ok {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		s.value.Set(reflect.Zero(s.value.Type()))
+		if err := node.Decode(s.value.Addr().Interface()); err != nil {
+			return pkgerrors.Wrapf(err, "invalid %s", key)
+		}
+		l.Sources[key] = source
+	}
+	return nil
+}
+
+// trackProjects records source for every project setting set in projects.
+func (l *Loaded) trackProjects(projects Projects, source string) {
+	for key, p := range projects {
+		for _, field := range p.fields() {
+			if !field.value.IsZero() {
+				l.Sources[projectsKey+"."+key+"."+field.key] = source
+			}
+		}
+	}
+}
+
+// foldDeprecated moves the deprecated per-project settings into Projects,
+// where ForProject resolves them. A project's own setting wins over the
+// deprecated one, whatever layer each came from.
+func (l *Loaded) foldDeprecated() {
+	c := &l.Config
+
+	// fold applies set to the project's settings and records where the
+	// value came from, unless set reports that the project has its own.
+	fold := func(projectKey, fieldKey, settingKey string, set func(p *Project) bool) {
+		p := c.Projects[projectKey]
+		if !set(&p) {
+			return
+		}
+		if c.Projects == nil {
+			c.Projects = Projects{}
+		}
+		c.Projects[projectKey] = p
+		l.Sources[projectsKey+"."+projectKey+"."+fieldKey] = l.Sources[settingKey] + " (deprecated " + settingKey + ")"
+	}
+
+	for key, status := range c.ProjectInProgressStatuses {
+		fold(key, "in_progress_status", "project_in_progress_statuses", func(p *Project) bool {
+			if p.InProgressStatus != nil {
+				return false
+			}
+			p.InProgressStatus = &status
+			return true
+		})
+	}
+	for key, status := range c.ProjectInReviewStatuses {
+		fold(key, "in_review_status", "project_in_review_statuses", func(p *Project) bool {
+			if p.InReviewStatus != nil {
+				return false
+			}
+			p.InReviewStatus = &status
+			return true
+		})
+	}
+	for key, fm := range c.FieldMap {
+		fold(key, "fields", "field_map", func(p *Project) bool {
+			if p.Fields != nil {
+				return false
+			}
+			p.Fields = &fm
+			return true
+		})
+	}
+}
+
+func findSetting(settings []Setting, key string) (Setting, bool) {
+	for _, s := range settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// decoder is implemented by settings that parse their own environment
+// variable format.
+type decoder interface {
+	Decode(value string) error
+}
+
+// decodeString parses an environment variable or default into v. Lists are
+// comma-separated and maps are "key:value" pairs separated by commas.
+func decodeString(v reflect.Value, value string) error {
+	if d, ok := v.Addr().Interface().(decoder); ok {
+		return d.Decode(value)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int64:
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+
+	case reflect.Slice:
+		if value == "" {
+			return nil
+		}
+		parts := strings.Split(value, ",")
+		v.Set(reflect.ValueOf(parts))
+
+	case reflect.Map:
+		m := map[string]string{}
+		if value != "" {
+			for _, pair := range strings.Split(value, ",") {
+				k, val, ok := strings.Cut(pair, ":")
+				if !ok {
+					return fmt.Errorf("invalid map item %q, want key:value", pair)
+				}
+				m[k] = val
+			}
+		}
+		v.Set(reflect.ValueOf(m))
+
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, accepting the same structure
+// as the JSON form.
+func (m *FieldMaps) UnmarshalYAML(node *yaml.Node) error {
+	var raw any
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return pkgerrors.Wrap(err, "invalid field map")
+	}
+	return m.Decode(string(data))
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (p *ExistingBranchPolicy) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	return p.Decode(value)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (m *GitHubClientMode) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	return m.Decode(value)
+}
@@ -0,0 +1,328 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testEnv isolates Load from the real user config and environment. It
+// returns the path of the global config file and of a repository directory.
+func testEnv(t *testing.T) (globalFile, repoPath string) {
+	t.Helper()
+
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, EnvConfigPrefix+"_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("HOME", dir)
+	globalFile, err := GlobalFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoPath = filepath.Join(dir, "repo")
+	if err := os.MkdirAll(repoPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return globalFile, repoPath
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if content == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadLayerPrecedence(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  string
+		repo    string
+		env     map[string]string
+		profile string
+		// want maps setting keys, including "projects.X.field" keys, to the
+		// expected value and source.
+		want map[string][2]string
+	}{
+		{
+			name: "defaults",
+			want: map[string][2]string{
+				"branch_prefix":  {"feature/", SourceDefault},
+				"verify_command": {"", SourceUnset},
+			},
+		},
+		{
+			name:   "global file over defaults",
+			global: "branch_prefix: global/\n",
+			want: map[string][2]string{
+				"branch_prefix": {"global/", "global"},
+			},
+		},
+		{
+			name:   "repo file over global file",
+			global: "branch_prefix: global/\nverify_command: make\n",
+			repo:   "branch_prefix: repo/\n",
+			want: map[string][2]string{
+				"branch_prefix":  {"repo/", "repo"},
+				"verify_command": {"make", "global"},
+			},
+		},
+		{
+			name:   "profile over repo file",
+			global: "branch_prefix: global/\nprofiles:\n  work:\n    branch_prefix: global-profile/\n",
+			repo:   "profile: work\nbranch_prefix: repo/\nprofiles:\n  work:\n    verify_command: make check\n",
+			want: map[string][2]string{
+				"branch_prefix":  {"global-profile/", "profile work (global)"},
+				"verify_command": {"make check", "profile work (repo)"},
+			},
+		},
+		{
+			name:    "profile option over the files' profile key",
+			global:  "profiles:\n  a:\n    branch_prefix: a/\n  b:\n    branch_prefix: b/\n",
+			repo:    "profile: a\n",
+			profile: "b",
+			want: map[string][2]string{
+				"branch_prefix": {"b/", "profile b (global)"},
+			},
+		},
+		{
+			name:   "environment over everything",
+			global: "branch_prefix: global/\nprofiles:\n  work:\n    branch_prefix: profile/\n",
+			repo:   "profile: work\nbranch_prefix: repo/\n",
+			env:    map[string]string{"JIRA_CLAUDE_BRANCH_PREFIX": "env/"},
+			want: map[string][2]string{
+				"branch_prefix": {"env/", "env JIRA_CLAUDE_BRANCH_PREFIX"},
+			},
+		},
+		{
+			name:   "projects merged key by key",
+			global: "projects:\n  SUI:\n    base_branch: develop\n    verify_command: make\n",
+			repo:   "projects:\n  SUI:\n    verify_command: make check\n",
+			want: map[string][2]string{
+				"projects.SUI.base_branch":    {"develop", "global"},
+				"projects.SUI.verify_command": {"make check", "repo"},
+			},
+		},
+		{
+			name:   "environment projects merged over files",
+			global: "projects:\n  SUI:\n    base_branch: develop\n    verify_command: make\n  PAY:\n    base_branch: release\n",
+			env:    map[string]string{"JIRA_CLAUDE_PROJECTS": `{"SUI":{"verify_command":"make ci"},"WEB":{"base_branch":"dev"}}`},
+			want: map[string][2]string{
+				"projects.SUI.base_branch":    {"develop", "global"},
+				"projects.SUI.verify_command": {"make ci", "env JIRA_CLAUDE_PROJECTS"},
+				"projects.PAY.base_branch":    {"release", "global"},
+				"projects.WEB.base_branch":    {"dev", "env JIRA_CLAUDE_PROJECTS"},
+			},
+		},
+		{
+			name:   "deprecated project settings folded into projects",
+			global: "project_in_progress_statuses:\n  SUI: Doing\n  PAY: Dev\nprojects:\n  PAY:\n    in_progress_status: Building\n",
+			env:    map[string]string{"JIRA_CLAUDE_PROJECT_IN_REVIEW_STATUSES": "SUI:"},
+			want: map[string][2]string{
+				"projects.SUI.in_progress_status": {"Doing", "global (deprecated project_in_progress_statuses)"},
+				"projects.PAY.in_progress_status": {"Building", "global"},
+				"projects.SUI.in_review_status":   {"", "env JIRA_CLAUDE_PROJECT_IN_REVIEW_STATUSES (deprecated project_in_review_statuses)"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			globalFile, repoPath := testEnv(t)
+			writeFile(t, globalFile, tt.global)
+			writeFile(t, filepath.Join(repoPath, RepoFile), tt.repo)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			loaded, err := Load(LoadOptions{RepoPath: repoPath, Profile: tt.profile})
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			for key, want := range tt.want {
+				if got := settingValue(t, loaded, key); got != want[0] {
+					t.Errorf("%s = %q, want %q", key, got, want[0])
+				}
+				if got := loaded.Sources[key]; got != want[1] {
+					t.Errorf("source of %s = %q, want %q", key, got, want[1])
+				}
+			}
+		})
+	}
+}
+
+// settingValue returns the value of a setting or project setting as a
+// string.
+func settingValue(t *testing.T, loaded *Loaded, key string) string {
+	t.Helper()
+
+	if rest, ok := strings.CutPrefix(key, projectsKey+"."); ok {
+		project, field, _ := strings.Cut(rest, ".")
+		value, ok := loaded.Config.Projects[project].Values()[field]
+		if !ok {
+			t.Fatalf("unknown project setting %s", key)
+		}
+		if s, ok := value.(*string); ok {
+			if s == nil {
+				return "<nil>"
+			}
+			return *s
+		}
+		return value.(string)
+	}
+
+	s, ok := findSetting(loaded.Config.Settings(), key)
+	if !ok {
+		t.Fatalf("unknown setting %s", key)
+	}
+	return s.Value().(string)
+}
+
+func TestLoadSourcesOnlyNameLoadedProjects(t *testing.T) {
+	globalFile, repoPath := testEnv(t)
+	writeFile(t, globalFile, "projects:\n  SUI:\n    base_branch: develop\n")
+	t.Setenv("JIRA_CLAUDE_PROJECTS", `{"WEB":{"base_branch":"dev"}}`)
+
+	loaded, err := Load(LoadOptions{RepoPath: repoPath})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for key := range loaded.Sources {
+		rest, ok := strings.CutPrefix(key, projectsKey+".")
+		if !ok {
+			continue
+		}
+		project, _, _ := strings.Cut(rest, ".")
+		if _, ok := loaded.Config.Projects[project]; !ok {
+			t.Errorf("source reported for %s, but project %s was not loaded", key, project)
+		}
+	}
+	if len(loaded.Config.Projects) != 2 {
+		t.Errorf("loaded projects %v, want SUI and WEB", loaded.Config.Projects)
+	}
+}
+
+func TestLoadDecodesEnvironment(t *testing.T) {
+	_, repoPath := testEnv(t)
+	t.Setenv("JIRA_CLAUDE_CLAUDE_TIMEOUT", "90s")
+	t.Setenv("JIRA_CLAUDE_REVIEWERS", "alice,org/team")
+	t.Setenv("JIRA_CLAUDE_POST_JIRA_COMMENT", "false")
+	t.Setenv("JIRA_CLAUDE_VERIFY_ATTEMPTS", "5")
+	t.Setenv("JIRA_CLAUDE_EXISTING_BRANCH", "Suffix")
+	t.Setenv("JIRA_CLAUDE_FIELD_MAP", `{"SUI":{"story_points":"customfield_10016"}}`)
+
+	loaded, err := Load(LoadOptions{RepoPath: repoPath})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	c := loaded.Config
+	if c.ClaudeTimeout.String() != "1m30s" {
+		t.Errorf("ClaudeTimeout = %s, want 1m30s", c.ClaudeTimeout)
+	}
+	if strings.Join(c.Reviewers, ",") != "alice,org/team" {
+		t.Errorf("Reviewers = %v", c.Reviewers)
+	}
+	if c.PostJiraComment {
+		t.Error("PostJiraComment = true, want false")
+	}
+	if c.VerifyAttempts != 5 {
+		t.Errorf("VerifyAttempts = %d, want 5", c.VerifyAttempts)
+	}
+	if c.ExistingBranch != ExistingBranchSuffix {
+		t.Errorf("ExistingBranch = %q, want suffix", c.ExistingBranch)
+	}
+	if fm, ok := c.FieldMapFor("SUI"); !ok || fm.StoryPoints != "customfield_10016" {
+		t.Errorf("FieldMapFor(SUI) = %+v, %v", fm, ok)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  string
+		env     map[string]string
+		profile string
+		want    string
+	}{
+		{
+			name:    "unknown profile",
+			global:  "profiles:\n  a:\n    branch_prefix: a/\n",
+			profile: "b",
+			want:    `profile "b" is not defined`,
+		},
+		{
+			name:   "unknown setting",
+			global: "branch_prefx: x/\n",
+			want:   `unknown setting "branch_prefx"`,
+		},
+		{
+			name: "invalid environment value",
+			env:  map[string]string{"JIRA_CLAUDE_VERIFY_ATTEMPTS": "many"},
+			want: "invalid JIRA_CLAUDE_VERIFY_ATTEMPTS",
+		},
+		{
+			name: "invalid environment projects",
+			env:  map[string]string{"JIRA_CLAUDE_PROJECTS": "{"},
+			want: "invalid JIRA_CLAUDE_PROJECTS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			globalFile, repoPath := testEnv(t)
+			writeFile(t, globalFile, tt.global)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := Load(LoadOptions{RepoPath: repoPath, Profile: tt.profile})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestForProject(t *testing.T) {
+	doing, skip := "Doing", ""
+	c := Config{
+		DefaultBaseBranch: "main",
+		BranchPrefix:      "feature/",
+		InProgressStatus:  "In Progress",
+		InReviewStatus:    "In Review",
+		Projects: Projects{
+			AllProjects: {BranchPrefix: "all/", InProgressStatus: &doing},
+			"SUI":       {BaseBranch: "develop", InReviewStatus: &skip},
+		},
+	}
+
+	sui := c.ForProject("SUI")
+	if sui.DefaultBaseBranch != "develop" || sui.BranchPrefix != "all/" {
+		t.Errorf("SUI branches = %q, %q; want develop, all/", sui.DefaultBaseBranch, sui.BranchPrefix)
+	}
+	if sui.InProgressStatus != "Doing" || sui.InReviewStatus != "" {
+		t.Errorf("SUI statuses = %q, %q; want Doing and no review transition", sui.InProgressStatus, sui.InReviewStatus)
+	}
+
+	other := c.ForProject("PAY")
+	if other.DefaultBaseBranch != "main" || other.InReviewStatus != "In Review" {
+		t.Errorf("PAY = %q, %q; want the global settings", other.DefaultBaseBranch, other.InReviewStatus)
+	}
+}
@@ -0,0 +1,87 @@
+// Package describe asks Claude to write the commit message and PR description
+// for a change from its actual diff, instead of canned text.
+package describe
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	"github.com/bsaliba1/jira-claude/internal/templates"
+	"github.com/bsaliba1/jira-claude/internal/verify"
+)
+
+// MaxDiffChars caps how much of the diff is put in the prompt. Claude can
+// read the changed files itself when the diff is cut short.
+const MaxDiffChars = 60000
+
+// CommitMarker and PRMarker introduce the two parts of Claude's reply. The
+// describe_prompt template asks for them.
+const (
+	CommitMarker = "=== COMMIT MESSAGE ==="
+	PRMarker     = "=== PR DESCRIPTION ==="
+)
+
+// Description is a commit message and PR description written by Claude.
+type Description struct {
+	CommitMessage string
+	PRBody        string
+}
+
+// PromptData is the data for the describe_prompt template, which asks
+// Claude to describe the staged diff for the ticket. The verification
+// report, when there is one, tells Claude how the change was checked.
+func PromptData(ticket *jira.Ticket, diff string, verification *verify.Report) templates.DescribeData {
+	return templates.DescribeData{
+		Ticket:       ticket,
+		Diff:         truncateDiff(diff, MaxDiffChars),
+		Verification: verification,
+		CommitMarker: CommitMarker,
+		PRMarker:     PRMarker,
+	}
+}
+
+// Parse extracts the commit message and PR description from Claude's reply.
+func Parse(output string) (*Description, error) {
+	commitAt := strings.Index(output, CommitMarker)
+	prAt := strings.Index(output, PRMarker)
+	if commitAt < 0 || prAt < 0 || prAt < commitAt {
+		return nil, fmt.Errorf("reply is missing the %q and %q markers", CommitMarker, PRMarker)
+	}
+
+	d := &Description{
+		CommitMessage: strings.TrimSpace(output[commitAt+len(CommitMarker) : prAt]),
+		PRBody:        strings.TrimSpace(output[prAt+len(PRMarker):]),
+	}
+	if d.CommitMessage == "" || d.PRBody == "" {
+		return nil, fmt.Errorf("reply has an empty commit message or PR description")
+	}
+
+	// Drop a code fence Claude may have wrapped the commit message in.
+	d.CommitMessage = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(d.CommitMessage, "```"), "```"))
+
+	return d, nil
+}
+
+// EnsureTicketRef appends a Refs trailer for the ticket to message unless it
+// already mentions the ticket key, so every commit can be traced back to Jira.
+func EnsureTicketRef(message, ticketKey string) string {
+	if strings.Contains(message, ticketKey) {
+		return message
+	}
+	return message + "\n\nRefs: " + ticketKey
+}
+
+// truncateDiff keeps the start of the diff, cut at a line boundary.
+func truncateDiff(diff string, max int) string {
+	diff = strings.TrimSpace(diff)
+	if len(diff) <= max {
+		return diff
+	}
+
+	head := diff[:max]
+	if i := strings.LastIndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+	return fmt.Sprintf("%s\n... (diff truncated, %d more characters)", head, len(diff)-len(head))
+}
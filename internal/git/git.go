@@ -2,83 +2,173 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"os/exec"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/bsaliba1/jira-claude/internal/proc"
 	pkgerrors "github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
 type Git struct {
 	repoPath string
+	timeout  time.Duration
 }
 
-func New(repoPath string) *Git {
-	return &Git{repoPath: repoPath}
+// Option configures optional Git behaviour.
+type Option func(*Git)
+
+// WithTimeout limits how long each git command may run. Zero means no limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(g *Git) {
+		g.timeout = timeout
+	}
+}
+
+func New(repoPath string, opts ...Option) *Git {
+	g := &Git{repoPath: repoPath}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
 }
 
-func (g *Git) run(args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
+// At returns a Git for another checkout with the same options.
+func (g *Git) At(repoPath string) *Git {
+	clone := *g
+	clone.repoPath = repoPath
+	return &clone
+}
+
+func (g *Git) run(ctx context.Context, args ...string) (string, error) {
+	return g.runEnv(ctx, nil, args...)
+}
+
+// runEnv runs git with env added to the environment.
+func (g *Git) runEnv(ctx context.Context, env []string, args ...string) (string, error) {
+	ctx, cancel := proc.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	cmd := proc.Command(ctx, "git", args...)
 	cmd.Dir = g.repoPath
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	log.Debug().Strs("args", args).Str("repo", g.repoPath).Msg("running git command")
+	log.Ctx(ctx).Debug().Strs("args", args).Str("repo", g.repoPath).Msg("running git command")
 
 	if err := cmd.Run(); err != nil {
-		return "", pkgerrors.Wrapf(err, "git %s failed: %s", strings.Join(args, " "), stderr.String())
+		return "", pkgerrors.Wrapf(proc.Err(ctx, err), "git %s failed: %s", strings.Join(args, " "), stderr.String())
 	}
 
 	return strings.TrimSpace(stdout.String()), nil
 }
 
 // CurrentBranch returns the name of the current branch.
-func (g *Git) CurrentBranch() (string, error) {
-	return g.run("rev-parse", "--abbrev-ref", "HEAD")
+func (g *Git) CurrentBranch(ctx context.Context) (string, error) {
+	return g.run(ctx, "rev-parse", "--abbrev-ref", "HEAD")
+}
+
+// HeadCommit returns the full hash of HEAD.
+func (g *Git) HeadCommit(ctx context.Context) (string, error) {
+	return g.run(ctx, "rev-parse", "HEAD")
 }
 
 // CreateBranch creates and checks out a new branch.
-func (g *Git) CreateBranch(branchName string) error {
-	_, err := g.run("checkout", "-b", branchName)
+func (g *Git) CreateBranch(ctx context.Context, branchName string) error {
+	_, err := g.run(ctx, "checkout", "-b", branchName)
 	return err
 }
 
 // Checkout switches to an existing branch.
-func (g *Git) Checkout(branchName string) error {
-	_, err := g.run("checkout", branchName)
+func (g *Git) Checkout(ctx context.Context, branchName string) error {
+	_, err := g.run(ctx, "checkout", branchName)
+	return err
+}
+
+// CheckoutDetached checks out ref with a detached HEAD.
+func (g *Git) CheckoutDetached(ctx context.Context, ref string) error {
+	_, err := g.run(ctx, "checkout", "--detach", ref)
 	return err
 }
 
 // BranchExists checks if a branch exists.
-func (g *Git) BranchExists(branchName string) bool {
-	_, err := g.run("rev-parse", "--verify", branchName)
+func (g *Git) BranchExists(ctx context.Context, branchName string) bool {
+	_, err := g.run(ctx, "rev-parse", "--verify", branchName)
 	return err == nil
 }
 
 // DeleteBranch deletes a local branch.
-func (g *Git) DeleteBranch(branchName string) error {
-	_, err := g.run("branch", "-D", branchName)
+func (g *Git) DeleteBranch(ctx context.Context, branchName string) error {
+	_, err := g.run(ctx, "branch", "-D", branchName)
+	return err
+}
+
+// RemoteBranchExists reports whether origin has a branch with the given name.
+func (g *Git) RemoteBranchExists(ctx context.Context, branchName string) (bool, error) {
+	out, err := g.run(ctx, "ls-remote", "--heads", "origin", branchName)
+	if err != nil {
+		return false, err
+	}
+	return out != "", nil
+}
+
+// FetchBranch updates origin/<branchName> from the remote.
+func (g *Git) FetchBranch(ctx context.Context, branchName string) error {
+	_, err := g.run(ctx, "fetch", "origin", branchName)
+	return err
+}
+
+// TrackBranch creates a local branch tracking origin/<branchName> without
+// checking it out.
+func (g *Git) TrackBranch(ctx context.Context, branchName string) error {
+	_, err := g.run(ctx, "branch", "--track", branchName, "origin/"+branchName)
+	return err
+}
+
+// CommitsAhead returns the number of commits on ref that are not on base.
+func (g *Git) CommitsAhead(ctx context.Context, base, ref string) (int, error) {
+	out, err := g.run(ctx, "rev-list", "--count", base+".."+ref)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(out)
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "unexpected rev-list output %q", out)
+	}
+	return n, nil
+}
+
+// Clone clones url into dest, relative to the Git's directory.
+func (g *Git) Clone(ctx context.Context, url, dest string) error {
+	_, err := g.run(ctx, "clone", url, dest)
 	return err
 }
 
 // Fetch fetches from remote.
-func (g *Git) Fetch() error {
-	_, err := g.run("fetch", "origin")
+func (g *Git) Fetch(ctx context.Context) error {
+	_, err := g.run(ctx, "fetch", "origin")
 	return err
 }
 
 // Pull pulls the current branch from remote.
-func (g *Git) Pull() error {
-	_, err := g.run("pull")
+func (g *Git) Pull(ctx context.Context) error {
+	_, err := g.run(ctx, "pull")
 	return err
 }
 
 // HasChanges returns true if there are uncommitted changes.
-func (g *Git) HasChanges() (bool, error) {
-	status, err := g.run("status", "--porcelain")
+func (g *Git) HasChanges(ctx context.Context) (bool, error) {
+	status, err := g.run(ctx, "status", "--porcelain")
 	if err != nil {
 		return false, err
 	}
@@ -86,35 +176,54 @@ func (g *Git) HasChanges() (bool, error) {
 }
 
 // AddAll stages all changes.
-func (g *Git) AddAll() error {
-	_, err := g.run("add", "-A")
+func (g *Git) AddAll(ctx context.Context) error {
+	_, err := g.run(ctx, "add", "-A")
 	return err
 }
 
 // Commit creates a commit with the given message.
-func (g *Git) Commit(message string) error {
-	_, err := g.run("commit", "-m", message)
+func (g *Git) Commit(ctx context.Context, message string) error {
+	_, err := g.run(ctx, "commit", "-m", message)
+	return err
+}
+
+// CommitNoVerify creates a commit with the given message, skipping commit
+// hooks.
+func (g *Git) CommitNoVerify(ctx context.Context, message string) error {
+	_, err := g.run(ctx, "commit", "--no-verify", "-m", message)
+	return err
+}
+
+// Stash stashes all changes, including untracked files, under message.
+func (g *Git) Stash(ctx context.Context, message string) error {
+	_, err := g.run(ctx, "stash", "push", "--include-untracked", "-m", message)
 	return err
 }
 
 // Push pushes the current branch to origin.
-func (g *Git) Push() error {
-	branch, err := g.CurrentBranch()
+func (g *Git) Push(ctx context.Context) error {
+	branch, err := g.CurrentBranch(ctx)
 	if err != nil {
 		return err
 	}
-	_, err = g.run("push", "-u", "origin", branch)
+	_, err = g.run(ctx, "push", "-u", "origin", branch)
 	return err
 }
 
+// ConfigValue returns the value of a git config key such as user.email, or
+// an error if it is not set.
+func (g *Git) ConfigValue(ctx context.Context, key string) (string, error) {
+	return g.run(ctx, "config", "--get", key)
+}
+
 // GetRemoteURL returns the remote URL for origin.
-func (g *Git) GetRemoteURL() (string, error) {
-	return g.run("remote", "get-url", "origin")
+func (g *Git) GetRemoteURL(ctx context.Context) (string, error) {
+	return g.run(ctx, "remote", "get-url", "origin")
 }
 
 // EnsureClean returns an error if the working directory is not clean.
-func (g *Git) EnsureClean() error {
-	hasChanges, err := g.HasChanges()
+func (g *Git) EnsureClean(ctx context.Context) error {
+	hasChanges, err := g.HasChanges(ctx)
 	if err != nil {
 		return err
 	}
@@ -125,7 +234,84 @@ func (g *Git) EnsureClean() error {
 }
 
 // RebaseOnto rebases current branch onto the given base.
-func (g *Git) RebaseOnto(base string) error {
-	_, err := g.run("rebase", base)
+func (g *Git) RebaseOnto(ctx context.Context, base string) error {
+	_, err := g.run(ctx, "rebase", base)
 	return err
 }
+
+// AddWorktree creates a new branch at startPoint and checks it out in a
+// linked worktree at path.
+func (g *Git) AddWorktree(ctx context.Context, path, branchName, startPoint string) error {
+	_, err := g.run(ctx, "worktree", "add", "-b", branchName, path, startPoint)
+	return err
+}
+
+// AddWorktreeForBranch checks out an existing branch in a linked worktree at
+// path.
+func (g *Git) AddWorktreeForBranch(ctx context.Context, path, branchName string) error {
+	_, err := g.run(ctx, "worktree", "add", path, branchName)
+	return err
+}
+
+// RemoveWorktree removes the linked worktree at path, discarding any
+// uncommitted changes it contains.
+func (g *Git) RemoveWorktree(ctx context.Context, path string) error {
+	_, err := g.run(ctx, "worktree", "remove", "--force", path)
+	return err
+}
+
+// PruneWorktrees cleans up administrative data for worktrees whose
+// directories no longer exist.
+func (g *Git) PruneWorktrees(ctx context.Context) error {
+	_, err := g.run(ctx, "worktree", "prune")
+	return err
+}
+
+// StagedDiff returns the diff of the changes staged for commit.
+func (g *Git) StagedDiff(ctx context.Context) (string, error) {
+	return g.run(ctx, "diff", "--cached")
+}
+
+// WorkingTreeDiff returns the diff from base to the working tree, covering
+// commits made since HEAD diverged from base, uncommitted changes and
+// untracked files that are not ignored.
+func (g *Git) WorkingTreeDiff(ctx context.Context, base string) (string, error) {
+	return g.diffWorkingTree(ctx, base)
+}
+
+// WorkingTreeFiles returns the paths WorkingTreeDiff would show changes to.
+func (g *Git) WorkingTreeFiles(ctx context.Context, base string) ([]string, error) {
+	out, err := g.diffWorkingTree(ctx, base, "--name-only")
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// diffWorkingTree diffs the point HEAD diverged from base against the whole
+// working tree, so later commits on base do not show up. It stages into a
+// temporary index, so the real index is left as it was.
+func (g *Git) diffWorkingTree(ctx context.Context, base string, args ...string) (string, error) {
+	forkPoint, err := g.run(ctx, "merge-base", base, "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp("", "jira-claude-index-")
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to create temporary index")
+	}
+	defer os.RemoveAll(dir)
+
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(dir, "index")}
+	if _, err := g.runEnv(ctx, env, "read-tree", "HEAD"); err != nil {
+		return "", err
+	}
+	if _, err := g.runEnv(ctx, env, "add", "--all"); err != nil {
+		return "", err
+	}
+	return g.runEnv(ctx, env, append([]string{"diff", "--cached"}, append(args, forkPoint)...)...)
+}
@@ -0,0 +1,75 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Client is the GitHub functionality jira-claude needs. GitHub implements it
+// by running the gh CLI and RESTClient by calling the REST API directly.
+//
+// CreatePR may return the new PR's URL along with an error when the PR was
+// opened but a later step, such as requesting reviewers, failed.
+type Client interface {
+	AuthStatus(ctx context.Context) error
+	CreatePR(ctx context.Context, title, body, head, baseBranch string, reviewers []string) (string, error)
+	OpenPRForBranch(ctx context.Context, branchName string) (string, error)
+	GetPRForBranch(ctx context.Context, branchName string) (int, error)
+	GetPRDetails(ctx context.Context, prNumber int) (title, url string, err error)
+	GetPRComments(ctx context.Context, prNumber int) (*PRComments, error)
+	ReplyToComment(ctx context.Context, prNumber int, commentID int64, body string) error
+}
+
+var (
+	_ Client = (*GitHub)(nil)
+	_ Client = (*RESTClient)(nil)
+)
+
+// Repo identifies a repository on a GitHub host.
+type Repo struct {
+	Host  string
+	Owner string
+	Name  string
+}
+
+func (r Repo) String() string {
+	return r.Owner + "/" + r.Name
+}
+
+// APIURL returns the REST API root for the repository's host:
+// api.github.com for github.com and /api/v3 on GitHub Enterprise servers.
+func (r Repo) APIURL() string {
+	if r.Host == "github.com" {
+		return "https://api.github.com"
+	}
+	return "https://" + r.Host + "/api/v3"
+}
+
+// ParseRemote parses a git remote URL such as
+// https://github.com/owner/repo.git or git@github.com:owner/repo.git.
+func ParseRemote(remote string) (Repo, error) {
+	remote = strings.TrimSpace(remote)
+
+	var host, path string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return Repo{}, fmt.Errorf("invalid remote URL %q: %w", remote, err)
+		}
+		host, path = u.Hostname(), u.Path
+	} else if at, rest, ok := strings.Cut(remote, ":"); ok {
+		// scp-like syntax: [user@]host:owner/repo
+		if _, h, ok := strings.Cut(at, "@"); ok {
+			at = h
+		}
+		host, path = at, rest
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimSuffix(path, ".git"), "/"), "/")
+	if host == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Repo{}, fmt.Errorf("remote %q is not a GitHub repository URL", remote)
+	}
+	return Repo{Host: strings.ToLower(host), Owner: parts[0], Name: parts[1]}, nil
+}
@@ -2,40 +2,67 @@ package github
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"io"
 	"strings"
 
 	pkgerrors "github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
-// ReviewComment represents a single review comment on a PR.
+// CommentKind says where on a PR a piece of feedback was left.
+type CommentKind string
+
+const (
+	// KindInline is a review comment on a line of the diff.
+	KindInline CommentKind = "inline"
+	// KindReview is the summary body of a submitted review.
+	KindReview CommentKind = "review"
+	// KindConversation is a comment on the PR's conversation tab.
+	KindConversation CommentKind = "conversation"
+)
+
+// ReviewComment represents a single piece of feedback on a PR: an inline
+// review comment, a review summary or a conversation comment.
 type ReviewComment struct {
-	ID       int64  `json:"id"`
-	Author   string `json:"user.login"`
-	Body     string `json:"body"`
-	Path     string `json:"path"`
-	Line     int    `json:"line"`
-	DiffHunk string `json:"diff_hunk"`
-	URL      string `json:"html_url"`
+	Kind     CommentKind `json:"kind"`
+	ID       int64       `json:"id"`
+	Author   string      `json:"user.login"`
+	Body     string      `json:"body"`
+	Path     string      `json:"path"`
+	Line     int         `json:"line"`
+	DiffHunk string      `json:"diff_hunk"`
+	URL      string      `json:"html_url"`
+	// State is a review's state, e.g. CHANGES_REQUESTED. It is empty for
+	// other kinds.
+	State string `json:"state"`
+}
+
+// StateText returns a review's state in words, e.g. "changes requested".
+func (c ReviewComment) StateText() string {
+	return strings.ToLower(strings.ReplaceAll(c.State, "_", " "))
 }
 
-// reviewCommentJSON matches the GitHub API response structure.
-type reviewCommentJSON struct {
+// commentJSON matches the GitHub API's review comment, review and issue
+// comment resources, which share these fields.
+type commentJSON struct {
 	ID       int64  `json:"id"`
 	Body     string `json:"body"`
 	Path     string `json:"path"`
 	Line     int    `json:"line"`
 	DiffHunk string `json:"diff_hunk"`
 	HTMLURL  string `json:"html_url"`
+	State    string `json:"state"`
 	User     struct {
 		Login string `json:"login"`
 	} `json:"user"`
 }
 
-// PRComments contains all review comments for a PR.
+// PRComments contains all feedback on a PR, inline comments first, then
+// review summaries, then conversation comments, each oldest first.
 type PRComments struct {
 	PRNumber int
 	PRTitle  string
@@ -43,6 +70,63 @@ type PRComments struct {
 	Comments []ReviewComment
 }
 
+// OfKind returns the comments of the given kind.
+func (p *PRComments) OfKind(kind CommentKind) []ReviewComment {
+	var comments []ReviewComment
+	for _, c := range p.Comments {
+		if c.Kind == kind {
+			comments = append(comments, c)
+		}
+	}
+	return comments
+}
+
+// Inline returns the review comments left on lines of the diff.
+func (p *PRComments) Inline() []ReviewComment { return p.OfKind(KindInline) }
+
+// Reviews returns the summaries of submitted reviews.
+func (p *PRComments) Reviews() []ReviewComment { return p.OfKind(KindReview) }
+
+// Conversation returns the comments on the PR's conversation tab.
+func (p *PRComments) Conversation() []ReviewComment { return p.OfKind(KindConversation) }
+
+// commentPaths returns the API paths listing a PR's inline comments,
+// reviews and conversation comments, relative to repos/owner/name.
+func commentPaths(prNumber int) map[CommentKind]string {
+	return map[CommentKind]string{
+		KindInline:       fmt.Sprintf("pulls/%d/comments", prNumber),
+		KindReview:       fmt.Sprintf("pulls/%d/reviews", prNumber),
+		KindConversation: fmt.Sprintf("issues/%d/comments", prNumber),
+	}
+}
+
+// commentKinds is the order feedback is listed in.
+var commentKinds = []CommentKind{KindInline, KindReview, KindConversation}
+
+// convertComments converts API comments of the given kind to our
+// ReviewComment type. Reviews without a body, which only carry inline
+// comments or an approval, and dismissed reviews are left out.
+func convertComments(kind CommentKind, rawComments []commentJSON) []ReviewComment {
+	comments := make([]ReviewComment, 0, len(rawComments))
+	for _, rc := range rawComments {
+		if kind == KindReview && (strings.TrimSpace(rc.Body) == "" || rc.State == "DISMISSED" || rc.State == "PENDING") {
+			continue
+		}
+		comments = append(comments, ReviewComment{
+			Kind:     kind,
+			ID:       rc.ID,
+			Author:   rc.User.Login,
+			Body:     rc.Body,
+			Path:     rc.Path,
+			Line:     rc.Line,
+			DiffHunk: rc.DiffHunk,
+			URL:      rc.HTMLURL,
+			State:    rc.State,
+		})
+	}
+	return comments
+}
+
 // prViewJSON matches the gh pr view --json output.
 type prViewJSON struct {
 	Number int    `json:"number"`
@@ -50,23 +134,17 @@ type prViewJSON struct {
 	URL    string `json:"url"`
 }
 
-// GetPRForBranch detects the PR number for the current branch.
-func (g *GitHub) GetPRForBranch() (int, error) {
-	cmd := exec.Command("gh", "pr", "view", "--json", "number")
-	cmd.Dir = g.repoPath
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// GetPRForBranch detects the PR number for the branch.
+func (g *GitHub) GetPRForBranch(ctx context.Context, branchName string) (int, error) {
+	log.Ctx(ctx).Debug().Str("branch", branchName).Msg("detecting PR for branch")
 
-	log.Debug().Msg("detecting PR for current branch")
-
-	if err := cmd.Run(); err != nil {
-		return 0, pkgerrors.Wrapf(err, "failed to detect PR for branch: %s", stderr.String())
+	out, err := g.run(ctx, nil, "pr", "view", branchName, "--json", "number")
+	if err != nil {
+		return 0, pkgerrors.Wrap(err, "failed to detect PR for branch")
 	}
 
 	var result prViewJSON
-	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+	if err := json.Unmarshal(out, &result); err != nil {
 		return 0, pkgerrors.Wrap(err, "failed to parse gh pr view output")
 	}
 
@@ -74,142 +152,91 @@ func (g *GitHub) GetPRForBranch() (int, error) {
 }
 
 // GetPRDetails fetches PR title and URL.
-func (g *GitHub) GetPRDetails(prNumber int) (title, url string, err error) {
-	cmd := exec.Command("gh", "pr", "view", fmt.Sprintf("%d", prNumber), "--json", "number,title,url")
-	cmd.Dir = g.repoPath
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+func (g *GitHub) GetPRDetails(ctx context.Context, prNumber int) (title, url string, err error) {
+	log.Ctx(ctx).Debug().Int("pr", prNumber).Msg("fetching PR details")
 
-	log.Debug().Int("pr", prNumber).Msg("fetching PR details")
-
-	if err := cmd.Run(); err != nil {
-		return "", "", pkgerrors.Wrapf(err, "failed to get PR details: %s", stderr.String())
+	out, err := g.run(ctx, nil, "pr", "view", fmt.Sprintf("%d", prNumber), "--json", "number,title,url")
+	if err != nil {
+		return "", "", pkgerrors.Wrap(err, "failed to get PR details")
 	}
 
 	var result prViewJSON
-	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+	if err := json.Unmarshal(out, &result); err != nil {
 		return "", "", pkgerrors.Wrap(err, "failed to parse PR details")
 	}
 
 	return result.Title, result.URL, nil
 }
 
-// GetPRComments fetches all review comments for a PR.
-func (g *GitHub) GetPRComments(prNumber int) (*PRComments, error) {
+// GetPRComments fetches all feedback on a PR: every page of inline review
+// comments, review summaries and conversation comments.
+func (g *GitHub) GetPRComments(ctx context.Context, prNumber int) (*PRComments, error) {
 	// Get PR details first
-	title, url, err := g.GetPRDetails(prNumber)
+	title, url, err := g.GetPRDetails(ctx, prNumber)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get repository owner/name from remote
-	repoInfo, err := g.getRepoInfo()
+	repoInfo, err := g.getRepoInfo(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Fetch review comments via gh api
-	apiPath := fmt.Sprintf("repos/%s/pulls/%d/comments", repoInfo, prNumber)
-	cmd := exec.Command("gh", "api", apiPath)
-	cmd.Dir = g.repoPath
+	result := &PRComments{PRNumber: prNumber, PRTitle: title, PRURL: url}
+	paths := commentPaths(prNumber)
+	for _, kind := range commentKinds {
+		apiPath := fmt.Sprintf("repos/%s/%s?per_page=100", repoInfo, paths[kind])
+		log.Ctx(ctx).Debug().Int("pr", prNumber).Str("api", apiPath).Msg("fetching PR comments")
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	log.Debug().Int("pr", prNumber).Str("api", apiPath).Msg("fetching PR comments")
-
-	if err := cmd.Run(); err != nil {
-		return nil, pkgerrors.Wrapf(err, "failed to fetch PR comments: %s", stderr.String())
-	}
-
-	var rawComments []reviewCommentJSON
-	if err := json.Unmarshal(stdout.Bytes(), &rawComments); err != nil {
-		return nil, pkgerrors.Wrap(err, "failed to parse PR comments")
-	}
+		out, err := g.run(ctx, nil, "api", "--paginate", apiPath)
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "failed to fetch PR %s comments", kind)
+		}
 
-	// Convert to our ReviewComment type
-	comments := make([]ReviewComment, 0, len(rawComments))
-	for _, rc := range rawComments {
-		comments = append(comments, ReviewComment{
-			ID:       rc.ID,
-			Author:   rc.User.Login,
-			Body:     rc.Body,
-			Path:     rc.Path,
-			Line:     rc.Line,
-			DiffHunk: rc.DiffHunk,
-			URL:      rc.HTMLURL,
-		})
+		rawComments, err := decodePages(out)
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "failed to parse PR %s comments", kind)
+		}
+		result.Comments = append(result.Comments, convertComments(kind, rawComments)...)
 	}
 
-	return &PRComments{
-		PRNumber: prNumber,
-		PRTitle:  title,
-		PRURL:    url,
-		Comments: comments,
-	}, nil
+	return result, nil
 }
 
-// getRepoInfo returns the owner/repo string from the git remote.
-func (g *GitHub) getRepoInfo() (string, error) {
-	cmd := exec.Command("gh", "repo", "view", "--json", "nameWithOwner", "-q", ".nameWithOwner")
-	cmd.Dir = g.repoPath
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return "", pkgerrors.Wrapf(err, "failed to get repo info: %s", stderr.String())
+// decodePages decodes gh api --paginate output, which is one JSON array per
+// page written back to back.
+func decodePages(out []byte) ([]commentJSON, error) {
+	var all []commentJSON
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var page []commentJSON
+		err := dec.Decode(&page)
+		if errors.Is(err, io.EOF) {
+			return all, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
 	}
-
-	return strings.TrimSpace(stdout.String()), nil
 }
 
-// FormatCommentsAsPrompt formats PR comments as a prompt for Claude.
-func FormatCommentsAsPrompt(comments *PRComments, prefix string) string {
-	var sb strings.Builder
+// getRepoInfo returns the owner/repo string from the git remote, looking it
+// up only once.
+func (g *GitHub) getRepoInfo(ctx context.Context) (string, error) {
+	g.repoMu.Lock()
+	defer g.repoMu.Unlock()
 
-	sb.WriteString(fmt.Sprintf("# PR Review Comments for PR #%d: %s\n\n", comments.PRNumber, comments.PRTitle))
-
-	if prefix != "" {
-		sb.WriteString("## Additional Context\n\n")
-		sb.WriteString(prefix)
-		sb.WriteString("\n\n")
+	if g.nameWithOwner != "" {
+		return g.nameWithOwner, nil
 	}
 
-	sb.WriteString("## Review Comments to Address\n\n")
-
-	for i, comment := range comments.Comments {
-		sb.WriteString(fmt.Sprintf("### Comment %d by @%s\n", i+1, comment.Author))
-		sb.WriteString(fmt.Sprintf("**File:** `%s`", comment.Path))
-		if comment.Line > 0 {
-			sb.WriteString(fmt.Sprintf(" (line %d)", comment.Line))
-		}
-		sb.WriteString("\n")
-
-		if comment.DiffHunk != "" {
-			sb.WriteString("**Code context:**\n")
-			sb.WriteString("```\n")
-			sb.WriteString(comment.DiffHunk)
-			sb.WriteString("\n```\n")
-		}
-
-		sb.WriteString("**Comment:**\n")
-		sb.WriteString(comment.Body)
-		sb.WriteString("\n\n---\n\n")
+	out, err := g.run(ctx, nil, "repo", "view", "--json", "nameWithOwner", "-q", ".nameWithOwner")
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to get repo info")
 	}
 
-	sb.WriteString(`## Instructions
-Please address these review comments by making the necessary code changes.
-For each comment, either:
-1. Make the requested code changes directly
-2. If the comment is unclear or needs discussion, note what clarification is needed
-
-Focus on implementing the requested changes accurately and completely.
-`)
-
-	return sb.String()
+	g.nameWithOwner = strings.TrimSpace(string(out))
+	return g.nameWithOwner, nil
 }
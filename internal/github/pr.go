@@ -2,62 +2,131 @@ package github
 
 import (
 	"bytes"
-	"fmt"
-	"os/exec"
+	"context"
+	"encoding/json"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/bsaliba1/jira-claude/internal/proc"
 
 	pkgerrors "github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
+// GitHub implements Client by running the gh CLI.
 type GitHub struct {
 	repoPath string
+	timeout  time.Duration
+
+	repoMu        sync.Mutex
+	nameWithOwner string
 }
 
-func New(repoPath string) *GitHub {
-	return &GitHub{repoPath: repoPath}
+// Option configures optional GitHub behaviour.
+type Option func(*GitHub)
+
+// WithTimeout limits how long each gh command may run. Zero means no limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(g *GitHub) {
+		g.timeout = timeout
+	}
 }
 
-// CreatePR creates a pull request using the gh CLI.
-// Returns the PR URL.
-func (g *GitHub) CreatePR(title, body, baseBranch string) (string, error) {
-	args := []string{
-		"pr", "create",
-		"--title", title,
-		"--body", body,
-		"--base", baseBranch,
-		"--draft",
+func New(repoPath string, opts ...Option) *GitHub {
+	g := &GitHub{repoPath: repoPath}
+	for _, opt := range opts {
+		opt(g)
 	}
+	return g
+}
+
+// run executes gh in the repository, feeding it stdin if non-nil, and
+// returns its standard output.
+func (g *GitHub) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := proc.WithTimeout(ctx, g.timeout)
+	defer cancel()
 
-	cmd := exec.Command("gh", args...)
+	cmd := proc.Command(ctx, "gh", args...)
 	cmd.Dir = g.repoPath
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	log.Info().Str("title", title).Str("base", baseBranch).Msg("creating PR via gh CLI")
-
 	if err := cmd.Run(); err != nil {
-		return "", pkgerrors.Wrapf(err, "gh pr create failed: %s", stderr.String())
+		return nil, pkgerrors.Wrapf(proc.Err(ctx, err), "gh %s failed: %s", args[0], strings.TrimSpace(stderr.String()))
 	}
 
-	prURL := strings.TrimSpace(stdout.String())
-	return prURL, nil
+	return stdout.Bytes(), nil
+}
+
+// AuthStatus returns an error unless gh is logged in to the repository's
+// GitHub host.
+func (g *GitHub) AuthStatus(ctx context.Context) error {
+	_, err := g.run(ctx, nil, "auth", "status")
+	return err
+}
+
+// Token returns the token gh is logged in to host with.
+func (g *GitHub) Token(ctx context.Context, host string) (string, error) {
+	out, err := g.run(ctx, nil, "auth", "token", "--hostname", host)
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to get token from gh")
+	}
+	return strings.TrimSpace(string(out)), nil
 }
 
-// FormatPRBody creates a PR body with ticket reference and summary.
-func FormatPRBody(ticketKey, ticketSummary, jiraHost string) string {
-	var sb strings.Builder
+// CreatePR creates a draft pull request from head using the gh CLI, then
+// requests reviews from reviewers. Returns the PR URL, along with an error if
+// the PR was created but requesting reviewers failed.
+func (g *GitHub) CreatePR(ctx context.Context, title, body, head, baseBranch string, reviewers []string) (string, error) {
+	log.Ctx(ctx).Info().Str("title", title).Str("base", baseBranch).Msg("creating PR via gh CLI")
+
+	out, err := g.run(ctx, nil,
+		"pr", "create",
+		"--title", title,
+		"--body", body,
+		"--head", head,
+		"--base", baseBranch,
+		"--draft",
+	)
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "gh pr create failed")
+	}
+
+	// gh prints the new PR's URL as the last line of its output.
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	prURL := strings.TrimSpace(lines[len(lines)-1])
 
-	sb.WriteString("## Summary\n\n")
-	sb.WriteString(fmt.Sprintf("Implements [%s](%s/browse/%s): %s\n\n", ticketKey, jiraHost, ticketKey, ticketSummary))
-	sb.WriteString("## Changes\n\n")
-	sb.WriteString("_Changes implemented by Claude Code based on Jira ticket._\n\n")
-	sb.WriteString("## Test Plan\n\n")
-	sb.WriteString("- [ ] Review changes\n")
-	sb.WriteString("- [ ] Run tests\n")
-	sb.WriteString("- [ ] Manual verification\n")
+	if len(reviewers) > 0 {
+		if _, err := g.run(ctx, nil, "pr", "edit", prURL, "--add-reviewer", strings.Join(reviewers, ",")); err != nil {
+			return prURL, pkgerrors.Wrap(err, "created PR but failed to request reviewers")
+		}
+	}
 
-	return sb.String()
+	return prURL, nil
+}
+
+// OpenPRForBranch returns the URL of the open PR whose head is branchName,
+// or "" if there is none.
+func (g *GitHub) OpenPRForBranch(ctx context.Context, branchName string) (string, error) {
+	out, err := g.run(ctx, nil, "pr", "list", "--head", branchName, "--state", "open", "--json", "url", "--limit", "1")
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to list open PRs for branch")
+	}
+
+	var prs []struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(out, &prs); err != nil {
+		return "", pkgerrors.Wrap(err, "failed to parse gh pr list output")
+	}
+	if len(prs) == 0 {
+		return "", nil
+	}
+	return prs[0].URL, nil
 }
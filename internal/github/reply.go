@@ -1,18 +1,17 @@
 package github
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 
 	pkgerrors "github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
 // ReplyToComment posts a reply to a review comment.
-func (g *GitHub) ReplyToComment(prNumber int, commentID int64, body string) error {
-	repoInfo, err := g.getRepoInfo()
+func (g *GitHub) ReplyToComment(ctx context.Context, prNumber int, commentID int64, body string) error {
+	repoInfo, err := g.getRepoInfo(ctx)
 	if err != nil {
 		return err
 	}
@@ -27,18 +26,10 @@ func (g *GitHub) ReplyToComment(prNumber int, commentID int64, body string) erro
 		return pkgerrors.Wrap(err, "failed to marshal reply payload")
 	}
 
-	cmd := exec.Command("gh", "api", apiPath, "-X", "POST", "--input", "-")
-	cmd.Dir = g.repoPath
-	cmd.Stdin = bytes.NewReader(payloadBytes)
+	log.Ctx(ctx).Debug().Int("pr", prNumber).Int64("commentID", commentID).Msg("posting reply to comment")
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	log.Debug().Int("pr", prNumber).Int64("commentID", commentID).Msg("posting reply to comment")
-
-	if err := cmd.Run(); err != nil {
-		return pkgerrors.Wrapf(err, "failed to post reply: %s", stderr.String())
+	if _, err := g.run(ctx, payloadBytes, "api", apiPath, "-X", "POST", "--input", "-"); err != nil {
+		return pkgerrors.Wrap(err, "failed to post reply")
 	}
 
 	return nil
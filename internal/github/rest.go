@@ -0,0 +1,463 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bsaliba1/jira-claude/internal/proc"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Secondary rate limits are retried up to maxRetries times, waiting for the
+// Retry-After or X-RateLimit-Reset the response names, or else
+// defaultRetryWait. Waits longer than maxRetryWait fail instead.
+const (
+	maxRetries       = 3
+	defaultRetryWait = time.Minute
+	maxRetryWait     = 5 * time.Minute
+)
+
+// RESTClient talks to the GitHub REST API over HTTP.
+type RESTClient struct {
+	repo    Repo
+	token   string
+	baseURL string
+	timeout time.Duration
+	http    *http.Client
+
+	// meta is the repository as the API reports it, fetched once.
+	metaMu sync.Mutex
+	meta   *repoMeta
+}
+
+// RESTOption configures optional RESTClient behaviour.
+type RESTOption func(*RESTClient)
+
+// WithBaseURL sends requests to a different API root, such as a GitHub
+// Enterprise server's https://ghe.example.com/api/v3.
+func WithBaseURL(baseURL string) RESTOption {
+	return func(c *RESTClient) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimSuffix(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client requests are sent with.
+func WithHTTPClient(client *http.Client) RESTOption {
+	return func(c *RESTClient) {
+		c.http = client
+	}
+}
+
+// WithRequestTimeout limits how long each attempt at a request may take;
+// waits before retrying a rate-limited request are not counted. Zero means
+// no limit.
+func WithRequestTimeout(timeout time.Duration) RESTOption {
+	return func(c *RESTClient) {
+		c.timeout = timeout
+	}
+}
+
+// NewRESTClient creates a client for repo authenticating with token. The
+// API root defaults to the one for repo's host.
+func NewRESTClient(repo Repo, token string, opts ...RESTOption) *RESTClient {
+	c := &RESTClient{
+		repo:    repo,
+		token:   token,
+		baseURL: repo.APIURL(),
+		http:    http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is a non-2xx response from the GitHub API.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitHub API %s %s returned %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// repoMeta is the part of the repository resource the client uses.
+type repoMeta struct {
+	FullName      string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// repoPath returns the repos/owner/name prefix, using the canonical name
+// after a rename or transfer.
+func (c *RESTClient) repoPath(ctx context.Context) (string, error) {
+	meta, err := c.repoMeta(ctx)
+	if err != nil {
+		return "", err
+	}
+	return "repos/" + meta.FullName, nil
+}
+
+// repoMeta fetches the repository resource the first time it is needed.
+func (c *RESTClient) repoMeta(ctx context.Context) (*repoMeta, error) {
+	c.metaMu.Lock()
+	defer c.metaMu.Unlock()
+
+	if c.meta != nil {
+		return c.meta, nil
+	}
+
+	var meta repoMeta
+	if err := c.do(ctx, http.MethodGet, "repos/"+c.repo.String(), nil, &meta); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to get repo info")
+	}
+	c.meta = &meta
+	return c.meta, nil
+}
+
+// do sends a request to the API path and decodes the JSON response into
+// out, if non-nil. in, if non-nil, is sent as the JSON body.
+func (c *RESTClient) do(ctx context.Context, method, path string, in, out any) error {
+	data, _, err := c.request(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return pkgerrors.Wrapf(err, "failed to parse response to %s %s", method, path)
+	}
+	return nil
+}
+
+// getPages fetches every page of the list at path, following the Link
+// header's next URL.
+func (c *RESTClient) getPages(ctx context.Context, path string) ([]commentJSON, error) {
+	var all []commentJSON
+	for path != "" {
+		data, header, err := c.request(ctx, http.MethodGet, path, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page []commentJSON
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, pkgerrors.Wrapf(err, "failed to parse response to GET %s", path)
+		}
+		all = append(all, page...)
+		path = nextPage(header.Get("Link"))
+	}
+	return all, nil
+}
+
+// nextPage returns the rel="next" URL of a Link header, or "".
+func nextPage(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if ok && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+// request sends a request, retrying rate-limited ones, and returns the
+// body and headers of the successful response.
+func (c *RESTClient) request(ctx context.Context, method, path string, in any) ([]byte, http.Header, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, nil, pkgerrors.Wrap(err, "failed to marshal request body")
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, data, err := c.send(ctx, method, path, body)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return data, resp.Header, nil
+		}
+
+		apiErr := &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Message: errorMessage(data)}
+		wait, limited := rateLimitWait(resp, apiErr.Message)
+		if !limited || attempt >= maxRetries || wait > maxRetryWait {
+			return nil, nil, apiErr
+		}
+		// Give up now rather than wait past the caller's deadline.
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, nil, apiErr
+		}
+
+		log.Ctx(ctx).Warn().Str("path", path).Dur("wait", wait).Int("attempt", attempt+1).Msg("GitHub rate limit hit, retrying")
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, pkgerrors.Wrapf(proc.Err(ctx, ctx.Err()), "%s %s", method, path)
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one request to path, which is relative to the API root or,
+// for pagination links, absolute, and reads the response body.
+func (c *RESTClient) send(ctx context.Context, method, path string, body []byte) (*http.Response, []byte, error) {
+	ctx, cancel := proc.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	target := path
+	if !strings.Contains(path, "://") {
+		target = c.baseURL + "/" + path
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, nil, pkgerrors.Wrapf(err, "failed to build request %s %s", method, path)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	log.Ctx(ctx).Debug().Str("method", method).Str("path", path).Msg("calling GitHub API")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, pkgerrors.Wrapf(proc.Err(ctx, err), "GitHub API %s %s failed", method, path)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, pkgerrors.Wrapf(proc.Err(ctx, err), "failed to read response to %s %s", method, path)
+	}
+	return resp, data, nil
+}
+
+// errorMessage extracts the message from an API error body.
+func errorMessage(data []byte) string {
+	var body struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil || body.Message == "" {
+		return strings.TrimSpace(string(data))
+	}
+	msg := body.Message
+	for _, e := range body.Errors {
+		if e.Message != "" {
+			msg += "; " + e.Message
+		}
+	}
+	return msg
+}
+
+// rateLimitWait reports whether resp is a rate limit response and how long
+// to wait before retrying it.
+func rateLimitWait(resp *http.Response, message string) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return max(time.Until(time.Unix(reset, 0)), 0), true
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || strings.Contains(strings.ToLower(message), "rate limit") {
+		return defaultRetryWait, true
+	}
+	return 0, false
+}
+
+// AuthStatus returns an error unless the token is accepted.
+func (c *RESTClient) AuthStatus(ctx context.Context) error {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := c.do(ctx, http.MethodGet, "user", nil, &user); err != nil {
+		return pkgerrors.Wrap(err, "GitHub token was not accepted")
+	}
+	return nil
+}
+
+// pullJSON matches the pull request resource.
+type pullJSON struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CreatePR opens a draft pull request from head into baseBranch and
+// requests reviews from reviewers, where "org/team" names a team. Returns
+// the PR URL. If the PR is created but the reviewers cannot be requested,
+// the URL is returned together with the error.
+func (c *RESTClient) CreatePR(ctx context.Context, title, body, head, baseBranch string, reviewers []string) (string, error) {
+	repoPath, err := c.repoPath(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	log.Ctx(ctx).Info().Str("title", title).Str("base", baseBranch).Msg("creating PR via GitHub API")
+
+	var pr pullJSON
+	in := map[string]any{"title": title, "body": body, "head": head, "base": baseBranch, "draft": true}
+	if err := c.do(ctx, http.MethodPost, repoPath+"/pulls", in, &pr); err != nil {
+		return "", pkgerrors.Wrap(err, "failed to create PR")
+	}
+
+	if len(reviewers) > 0 {
+		users, teams := []string{}, []string{}
+		for _, r := range reviewers {
+			if _, team, ok := strings.Cut(r, "/"); ok {
+				teams = append(teams, team)
+			} else {
+				users = append(users, r)
+			}
+		}
+		in := map[string][]string{"reviewers": users, "team_reviewers": teams}
+		if err := c.do(ctx, http.MethodPost, fmt.Sprintf("%s/pulls/%d/requested_reviewers", repoPath, pr.Number), in, nil); err != nil {
+			return pr.HTMLURL, pkgerrors.Wrap(err, "created PR but failed to request reviewers")
+		}
+	}
+
+	return pr.HTMLURL, nil
+}
+
+// openPRs lists the open PRs whose head is branchName.
+func (c *RESTClient) openPRs(ctx context.Context, branchName string) ([]pullJSON, error) {
+	repoPath, err := c.repoPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := c.repoMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"head": {meta.Owner.Login + ":" + branchName}, "state": {"open"}}
+	var prs []pullJSON
+	if err := c.do(ctx, http.MethodGet, repoPath+"/pulls?"+query.Encode(), nil, &prs); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to list open PRs for branch")
+	}
+	return prs, nil
+}
+
+// OpenPRForBranch returns the URL of the open PR whose head is branchName,
+// or "" if there is none.
+func (c *RESTClient) OpenPRForBranch(ctx context.Context, branchName string) (string, error) {
+	prs, err := c.openPRs(ctx, branchName)
+	if err != nil || len(prs) == 0 {
+		return "", err
+	}
+	return prs[0].HTMLURL, nil
+}
+
+// GetPRForBranch returns the number of the open PR whose head is
+// branchName.
+func (c *RESTClient) GetPRForBranch(ctx context.Context, branchName string) (int, error) {
+	log.Ctx(ctx).Debug().Str("branch", branchName).Msg("detecting PR for branch")
+
+	prs, err := c.openPRs(ctx, branchName)
+	if err != nil {
+		return 0, pkgerrors.Wrap(err, "failed to detect PR for branch")
+	}
+	if len(prs) == 0 {
+		return 0, fmt.Errorf("no open PR found for branch %s", branchName)
+	}
+	return prs[0].Number, nil
+}
+
+// GetPRDetails fetches PR title and URL.
+func (c *RESTClient) GetPRDetails(ctx context.Context, prNumber int) (title, url string, err error) {
+	log.Ctx(ctx).Debug().Int("pr", prNumber).Msg("fetching PR details")
+
+	repoPath, err := c.repoPath(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	var pr pullJSON
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/pulls/%d", repoPath, prNumber), nil, &pr); err != nil {
+		return "", "", pkgerrors.Wrap(err, "failed to get PR details")
+	}
+	return pr.Title, pr.HTMLURL, nil
+}
+
+// GetPRComments fetches all feedback on a PR: every page of inline review
+// comments, review summaries and conversation comments.
+func (c *RESTClient) GetPRComments(ctx context.Context, prNumber int) (*PRComments, error) {
+	title, prURL, err := c.GetPRDetails(ctx, prNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	repoPath, err := c.repoPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PRComments{PRNumber: prNumber, PRTitle: title, PRURL: prURL}
+	paths := commentPaths(prNumber)
+	for _, kind := range commentKinds {
+		apiPath := fmt.Sprintf("%s/%s?per_page=100", repoPath, paths[kind])
+		log.Ctx(ctx).Debug().Int("pr", prNumber).Str("api", apiPath).Msg("fetching PR comments")
+
+		rawComments, err := c.getPages(ctx, apiPath)
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "failed to fetch PR %s comments", kind)
+		}
+		result.Comments = append(result.Comments, convertComments(kind, rawComments)...)
+	}
+
+	return result, nil
+}
+
+// ReplyToComment posts a reply to a review comment.
+func (c *RESTClient) ReplyToComment(ctx context.Context, prNumber int, commentID int64, body string) error {
+	repoPath, err := c.repoPath(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Debug().Int("pr", prNumber).Int64("commentID", commentID).Msg("posting reply to comment")
+
+	apiPath := fmt.Sprintf("%s/pulls/%d/comments/%d/replies", repoPath, prNumber, commentID)
+	if err := c.do(ctx, http.MethodPost, apiPath, map[string]string{"body": body}, nil); err != nil {
+		return pkgerrors.Wrap(err, "failed to post reply")
+	}
+	return nil
+}
@@ -0,0 +1,385 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRepo is the repository every fake server below serves.
+var testRepo = Repo{Host: "github.com", Owner: "acme", Name: "widgets"}
+
+// newTestClient starts a server for handler and returns a client pointed
+// at it.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *RESTClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewRESTClient(testRepo, "test-token", WithBaseURL(srv.URL))
+}
+
+// writeJSON writes v as the response body.
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Errorf("failed to write response: %v", err)
+	}
+}
+
+// repoJSON is the repository resource for testRepo.
+var repoJSON = map[string]any{
+	"full_name":      "acme/widgets",
+	"default_branch": "main",
+	"owner":          map[string]string{"login": "acme"},
+}
+
+func TestGetPRCommentsFollowsPagination(t *testing.T) {
+	var srvURL string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/widgets":
+			writeJSON(t, w, repoJSON)
+		case "/repos/acme/widgets/pulls/7":
+			writeJSON(t, w, map[string]any{"number": 7, "title": "Add widgets", "html_url": "https://github.com/acme/widgets/pull/7"})
+		case "/repos/acme/widgets/pulls/7/comments":
+			switch page := r.URL.Query().Get("page"); page {
+			case "":
+				w.Header().Set("Link", fmt.Sprintf(`<%s/repos/acme/widgets/pulls/7/comments?per_page=100&page=2>; rel="next", <%s/repos/acme/widgets/pulls/7/comments?per_page=100&page=3>; rel="last"`, srvURL, srvURL))
+				writeJSON(t, w, []map[string]any{{"id": 1, "body": "first", "path": "a.go", "line": 1, "user": map[string]string{"login": "ann"}}})
+			case "2":
+				w.Header().Set("Link", fmt.Sprintf(`<%s/repos/acme/widgets/pulls/7/comments?per_page=100&page=3>; rel="next"`, srvURL))
+				writeJSON(t, w, []map[string]any{{"id": 2, "body": "second", "path": "b.go", "line": 2, "user": map[string]string{"login": "bob"}}})
+			case "3":
+				writeJSON(t, w, []map[string]any{{"id": 3, "body": "third", "path": "c.go", "line": 3, "user": map[string]string{"login": "cat"}}})
+			default:
+				t.Errorf("unexpected page %q", page)
+			}
+		case "/repos/acme/widgets/pulls/7/reviews":
+			writeJSON(t, w, []map[string]any{
+				{"id": 10, "body": "Please restructure X", "state": "CHANGES_REQUESTED", "user": map[string]string{"login": "rev"}},
+				{"id": 11, "body": "", "state": "APPROVED", "user": map[string]string{"login": "rev"}},
+			})
+		case "/repos/acme/widgets/issues/7/comments":
+			writeJSON(t, w, []map[string]any{{"id": 20, "body": "general note", "user": map[string]string{"login": "dan"}}})
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+		}
+	})
+	srvURL = client.baseURL
+
+	comments, err := client.GetPRComments(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetPRComments: %v", err)
+	}
+
+	if comments.PRTitle != "Add widgets" || comments.PRURL != "https://github.com/acme/widgets/pull/7" {
+		t.Errorf("PR details = %q, %q", comments.PRTitle, comments.PRURL)
+	}
+
+	var got []string
+	for _, c := range comments.Comments {
+		got = append(got, fmt.Sprintf("%s:%d:%s", c.Kind, c.ID, c.Author))
+	}
+	want := []string{"inline:1:ann", "inline:2:bob", "inline:3:cat", "review:10:rev", "conversation:20:dan"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("comments = %v, want %v", got, want)
+	}
+	if reviews := comments.Reviews(); len(reviews) != 1 || reviews[0].State != "CHANGES_REQUESTED" {
+		t.Errorf("reviews = %+v", reviews)
+	}
+}
+
+func TestRequestRetriesSecondaryRateLimit(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusTooManyRequests} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			var calls atomic.Int32
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= 2 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(status)
+					writeJSON(t, w, map[string]string{"message": "You have exceeded a secondary rate limit."})
+					return
+				}
+				writeJSON(t, w, map[string]string{"login": "octocat"})
+			})
+
+			if err := client.AuthStatus(context.Background()); err != nil {
+				t.Fatalf("AuthStatus: %v", err)
+			}
+			if got := calls.Load(); got != 3 {
+				t.Errorf("server saw %d requests, want 3", got)
+			}
+		})
+	}
+}
+
+func TestRequestGivesUpOnRateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		timeout    time.Duration
+		wantCalls  int32
+	}{
+		{name: "after max retries", retryAfter: "0", wantCalls: maxRetries + 1},
+		{name: "wait longer than max", retryAfter: "3600", wantCalls: 1},
+		{name: "wait past deadline", retryAfter: "30", timeout: time.Second, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Retry-After", tt.retryAfter)
+				w.WriteHeader(http.StatusForbidden)
+				writeJSON(t, w, map[string]string{"message": "You have exceeded a secondary rate limit."})
+			})
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			err := client.AuthStatus(ctx)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+				t.Fatalf("AuthStatus error = %v, want a 403 APIError", err)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("server saw %d requests, want %d", got, tt.wantCalls)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("gave up after %s, want immediately", elapsed)
+			}
+		})
+	}
+}
+
+func TestRequestDoesNotRetryOtherErrors(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+		writeJSON(t, w, map[string]string{"message": "Resource not accessible by integration"})
+	})
+
+	err := client.AuthStatus(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "Resource not accessible by integration" {
+		t.Fatalf("AuthStatus error = %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+}
+
+func TestCreatePRReturnsURLWhenReviewersFail(t *testing.T) {
+	const prURL = "https://github.com/acme/widgets/pull/9"
+	var reviewers map[string][]string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/acme/widgets":
+			writeJSON(t, w, repoJSON)
+		case "POST /repos/acme/widgets/pulls":
+			w.WriteHeader(http.StatusCreated)
+			writeJSON(t, w, map[string]any{"number": 9, "title": "Add widgets", "html_url": prURL})
+		case "POST /repos/acme/widgets/pulls/9/requested_reviewers":
+			if err := json.NewDecoder(r.Body).Decode(&reviewers); err != nil {
+				t.Errorf("failed to decode reviewers: %v", err)
+			}
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			writeJSON(t, w, map[string]string{"message": "Reviews may only be requested from collaborators."})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	})
+
+	got, err := client.CreatePR(context.Background(), "Add widgets", "body", "feature", "main", []string{"typo-user", "acme/core"})
+	if err == nil {
+		t.Fatal("CreatePR succeeded, want reviewer error")
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("CreatePR error = %v, want the 422 API error", err)
+	}
+	if got != prURL {
+		t.Errorf("CreatePR URL = %q, want %q", got, prURL)
+	}
+	if want := map[string][]string{"reviewers": {"typo-user"}, "team_reviewers": {"core"}}; fmt.Sprint(reviewers) != fmt.Sprint(want) {
+		t.Errorf("requested reviewers = %v, want %v", reviewers, want)
+	}
+}
+
+// fakeGH is a gh stand-in that records its arguments, one per line, to the
+// file named by $GH_ARGS, prints a PR URL for "pr create" and fails "pr edit".
+const fakeGH = `#!/bin/sh
+printf '%s\n' "$@" >> "$GH_ARGS"
+echo >> "$GH_ARGS"
+case "$1 $2" in
+"pr create")
+	echo "https://github.com/acme/widgets/pull/9"
+	;;
+"pr edit")
+	echo "could not add reviewer: 'typo-user' not found" >&2
+	exit 1
+	;;
+esac
+`
+
+func TestGHCreatePRReturnsURLWhenReviewersFail(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gh is a shell script")
+	}
+	const prURL = "https://github.com/acme/widgets/pull/9"
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "gh"), []byte(fakeGH), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	argsFile := filepath.Join(dir, "args")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("GH_ARGS", argsFile)
+
+	got, err := New(dir).CreatePR(context.Background(), "Add widgets", "body", "feature", "main", []string{"typo-user", "acme/core"})
+	if err == nil || !strings.Contains(err.Error(), "failed to request reviewers") {
+		t.Errorf("CreatePR error = %v, want reviewer error", err)
+	}
+	if got != prURL {
+		t.Errorf("CreatePR URL = %q, want %q", got, prURL)
+	}
+
+	out, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(strings.TrimSpace(string(out)), "\n\n")
+	if len(calls) != 2 {
+		t.Fatalf("gh called %d times, want 2:\n%s", len(calls), out)
+	}
+	if strings.Contains(calls[0], "--reviewer") {
+		t.Errorf("gh pr create requested reviewers:\n%s", calls[0])
+	}
+	if want := "pr\nedit\n" + prURL + "\n--add-reviewer\ntypo-user,acme/core"; calls[1] != want {
+		t.Errorf("second gh call =\n%s\nwant\n%s", calls[1], want)
+	}
+}
+
+func TestEnterpriseBaseURL(t *testing.T) {
+	var paths []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if got := r.Header.Get("Authorization"); got != "Bearer ghe-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch r.URL.Path {
+		case "/api/v3/repos/acme/widgets":
+			writeJSON(t, w, repoJSON)
+		case "/api/v3/repos/acme/widgets/pulls/7":
+			writeJSON(t, w, map[string]any{"number": 7, "title": "Add widgets", "html_url": "https://ghe/acme/widgets/pull/7"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := Repo{Host: u.Host, Owner: "acme", Name: "widgets"}
+	if want := "https://" + u.Host + "/api/v3"; repo.APIURL() != want {
+		t.Fatalf("APIURL = %q, want %q", repo.APIURL(), want)
+	}
+
+	client := NewRESTClient(repo, "ghe-token", WithHTTPClient(srv.Client()))
+	title, _, err := client.GetPRDetails(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetPRDetails: %v", err)
+	}
+	if title != "Add widgets" {
+		t.Errorf("title = %q", title)
+	}
+	if want := []string{"/api/v3/repos/acme/widgets", "/api/v3/repos/acme/widgets/pulls/7"}; fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("requested %v, want %v", paths, want)
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		remote  string
+		want    Repo
+		wantAPI string
+	}{
+		{"https://github.com/acme/widgets.git", Repo{"github.com", "acme", "widgets"}, "https://api.github.com"},
+		{"git@github.com:acme/widgets.git", Repo{"github.com", "acme", "widgets"}, "https://api.github.com"},
+		{"ssh://git@GHE.example.com:2222/acme/widgets", Repo{"ghe.example.com", "acme", "widgets"}, "https://ghe.example.com/api/v3"},
+		{"https://ghe.example.com/acme/widgets/", Repo{"ghe.example.com", "acme", "widgets"}, "https://ghe.example.com/api/v3"},
+	}
+	for _, tt := range tests {
+		got, err := ParseRemote(tt.remote)
+		if err != nil {
+			t.Errorf("ParseRemote(%q): %v", tt.remote, err)
+			continue
+		}
+		if got != tt.want || got.APIURL() != tt.wantAPI {
+			t.Errorf("ParseRemote(%q) = %+v (%s), want %+v (%s)", tt.remote, got, got.APIURL(), tt.want, tt.wantAPI)
+		}
+	}
+
+	for _, remote := range []string{"/srv/git/widgets", "file:///srv/git/widgets", "https://github.com/acme"} {
+		if _, err := ParseRemote(remote); err == nil {
+			t.Errorf("ParseRemote(%q) succeeded, want an error", remote)
+		}
+	}
+}
+
+func TestRepoMetadataIsCached(t *testing.T) {
+	var repoCalls, replies atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/acme/widgets":
+			repoCalls.Add(1)
+			// The repository was renamed; later calls use its new name.
+			writeJSON(t, w, map[string]any{"full_name": "acme/gadgets", "owner": map[string]string{"login": "acme"}})
+		case r.Method == http.MethodPost && r.URL.Path == fmt.Sprintf("/repos/acme/gadgets/pulls/7/comments/%d/replies", 100+replies.Load()):
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["body"] != "Done." {
+				t.Errorf("reply body = %v, %v", body, err)
+			}
+			replies.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			writeJSON(t, w, map[string]any{"id": 1})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	})
+
+	for i := range 3 {
+		if err := client.ReplyToComment(context.Background(), 7, int64(100+i), "Done."); err != nil {
+			t.Fatalf("ReplyToComment: %v", err)
+		}
+	}
+	if got := replies.Load(); got != 3 {
+		t.Errorf("posted %d replies, want 3", got)
+	}
+	if got := repoCalls.Load(); got != 1 {
+		t.Errorf("fetched repo info %d times, want 1", got)
+	}
+}
@@ -1,12 +1,28 @@
 package jira
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/andygrunwald/go-jira"
 	pkgerrors "github.com/pkg/errors"
 )
 
+const githubIconURL = "https://github.com/favicon.ico"
+
 type Client interface {
-	GetTicket(ticketKey string) (*Ticket, error)
+	GetTicket(ticketKey string, opts *GetTicketOptions) (*Ticket, error)
+	SearchTickets(jql string) ([]*Ticket, error)
+	TransitionTicket(ticketKey, status string) error
+	AddComment(ticketKey, body string) error
+	LinkPullRequest(ticketKey, prURL, prTitle string) error
+	DownloadAttachment(attachment Attachment, destDir string) (string, error)
+	ListFields(projectKey string) ([]Field, error)
+	Myself() (string, error)
 }
 
 var _ Client = (*JiraClient)(nil)
@@ -29,12 +45,193 @@ func NewClient(host, username, apiToken string) (*JiraClient, error) {
 	return &JiraClient{client: client}, nil
 }
 
-func (c *JiraClient) GetTicket(ticketKey string) (*Ticket, error) {
+// Myself returns the display name and email of the authenticated user,
+// which confirms the host and credentials work.
+func (c *JiraClient) Myself() (string, error) {
+	user, _, err := c.client.User.GetSelf()
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to fetch the authenticated user")
+	}
+	if user.EmailAddress == "" {
+		return user.DisplayName, nil
+	}
+	return fmt.Sprintf("%s <%s>", user.DisplayName, user.EmailAddress), nil
+}
+
+// GetTicketOptions selects the optional context pulled in with a ticket.
+// A nil *GetTicketOptions fetches the ticket without any of it.
+type GetTicketOptions struct {
+	IncludeComments bool
+	IncludeSubtasks bool
+	IncludeLinks    bool
+
+	// MaxContextChars caps the combined text of the comments, subtasks and
+	// links. Links and subtasks are kept first, then the newest comments.
+	// Zero means no limit.
+	MaxContextChars int
+
+	// FieldMaps maps project keys to the custom fields that hold acceptance
+	// criteria, story points and other data. The "*" entry applies to
+	// projects without their own map.
+	FieldMaps map[string]FieldMap
+}
+
+func (c *JiraClient) GetTicket(ticketKey string, opts *GetTicketOptions) (*Ticket, error) {
 	issue, _, err := c.client.Issue.Get(ticketKey, nil)
 	if err != nil {
 		return nil, pkgerrors.Wrapf(err, "failed to get ticket %s", ticketKey)
 	}
 
+	ticket := ticketFromIssue(issue)
+
+	if opts == nil {
+		opts = &GetTicketOptions{}
+	}
+	if fm, ok := opts.FieldMaps[ticket.ProjectKey]; ok {
+		applyFieldMap(ticket, issue.Fields.Unknowns, &fm)
+	} else if fm, ok := opts.FieldMaps["*"]; ok {
+		applyFieldMap(ticket, issue.Fields.Unknowns, &fm)
+	}
+	if !opts.IncludeComments {
+		ticket.Comments = nil
+	}
+	if !opts.IncludeSubtasks {
+		ticket.Subtasks = nil
+	}
+	if !opts.IncludeLinks {
+		ticket.Links = nil
+	}
+	if opts.MaxContextChars > 0 {
+		limitContext(ticket, opts.MaxContextChars)
+	}
+
+	return ticket, nil
+}
+
+// SearchTickets returns every ticket matched by the JQL query, following
+// pagination until all results have been read.
+func (c *JiraClient) SearchTickets(jql string) ([]*Ticket, error) {
+	var tickets []*Ticket
+
+	err := c.client.Issue.SearchPages(jql, nil, func(issue jira.Issue) error {
+		tickets = append(tickets, ticketFromIssue(&issue))
+		return nil
+	})
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "failed to search tickets with JQL %q", jql)
+	}
+
+	return tickets, nil
+}
+
+// TransitionTicket moves the ticket into the named status. The transition is
+// looked up by its target status name, falling back to the transition name.
+func (c *JiraClient) TransitionTicket(ticketKey, status string) error {
+	transitions, _, err := c.client.Issue.GetTransitions(ticketKey)
+	if err != nil {
+		return pkgerrors.Wrapf(err, "failed to get transitions for %s", ticketKey)
+	}
+
+	var match *jira.Transition
+	for i, t := range transitions {
+		if strings.EqualFold(t.To.Name, status) {
+			match = &transitions[i]
+			break
+		}
+		if match == nil && strings.EqualFold(t.Name, status) {
+			match = &transitions[i]
+		}
+	}
+	if match == nil {
+		available := make([]string, 0, len(transitions))
+		for _, t := range transitions {
+			available = append(available, t.To.Name)
+		}
+		return fmt.Errorf("no transition to %q available for %s (available: %s)", status, ticketKey, strings.Join(available, ", "))
+	}
+
+	if _, err := c.client.Issue.DoTransition(ticketKey, match.ID); err != nil {
+		return pkgerrors.Wrapf(err, "failed to transition %s to %q", ticketKey, status)
+	}
+
+	return nil
+}
+
+// AddComment posts a comment, written in Jira wiki markup, on the ticket.
+func (c *JiraClient) AddComment(ticketKey, body string) error {
+	if _, _, err := c.client.Issue.AddComment(ticketKey, &jira.Comment{Body: body}); err != nil {
+		return pkgerrors.Wrapf(err, "failed to add comment to %s", ticketKey)
+	}
+	return nil
+}
+
+// LinkPullRequest attaches the PR to the ticket as a remote issue link. If the
+// ticket already links to the PR, the existing link is updated in place.
+func (c *JiraClient) LinkPullRequest(ticketKey, prURL, prTitle string) error {
+	link := &jira.RemoteLink{
+		GlobalID: "jira-claude:" + prURL,
+		Application: &jira.RemoteLinkApplication{
+			Type: "com.github",
+			Name: "GitHub",
+		},
+		Relationship: "pull request",
+		Object: &jira.RemoteLinkObject{
+			URL:   prURL,
+			Title: prTitle,
+			Icon: &jira.RemoteLinkIcon{
+				Url16x16: githubIconURL,
+				Title:    "GitHub Pull Request",
+			},
+		},
+	}
+
+	existing, _, err := c.client.Issue.GetRemoteLinks(ticketKey)
+	if err != nil {
+		return pkgerrors.Wrapf(err, "failed to get remote links for %s", ticketKey)
+	}
+
+	for _, rl := range *existing {
+		if rl.GlobalID == link.GlobalID || (rl.Object != nil && rl.Object.URL == prURL) {
+			if _, err := c.client.Issue.UpdateRemoteLink(ticketKey, rl.ID, link); err != nil {
+				return pkgerrors.Wrapf(err, "failed to update remote link on %s", ticketKey)
+			}
+			return nil
+		}
+	}
+
+	if _, _, err := c.client.Issue.AddRemoteLink(ticketKey, link); err != nil {
+		return pkgerrors.Wrapf(err, "failed to add remote link to %s", ticketKey)
+	}
+
+	return nil
+}
+
+// DownloadAttachment saves the attachment into destDir and returns the path
+// of the written file.
+func (c *JiraClient) DownloadAttachment(attachment Attachment, destDir string) (string, error) {
+	resp, err := c.client.Issue.DownloadAttachment(attachment.ID)
+	if err != nil {
+		return "", pkgerrors.Wrapf(err, "failed to download attachment %s", attachment.Filename)
+	}
+	defer resp.Body.Close()
+
+	// Prefix with the ID since filenames are not unique within a ticket.
+	path := filepath.Join(destDir, attachment.ID+"-"+filepath.Base(attachment.Filename))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to create attachment file")
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return "", pkgerrors.Wrapf(err, "failed to write attachment %s", attachment.Filename)
+	}
+
+	return path, nil
+}
+
+// ticketFromIssue converts a Jira issue into our Ticket representation.
+func ticketFromIssue(issue *jira.Issue) *Ticket {
 	ticket := &Ticket{
 		Key:        issue.Key,
 		Summary:    issue.Fields.Summary,
@@ -49,6 +246,10 @@ func (c *JiraClient) GetTicket(ticketKey string) (*Ticket, error) {
 		ticket.IssueType = issue.Fields.Type.Name
 	}
 
+	if issue.Fields.Status != nil {
+		ticket.Status = issue.Fields.Status.Name
+	}
+
 	if issue.Fields.Priority != nil {
 		ticket.Priority = issue.Fields.Priority.Name
 	}
@@ -57,18 +258,117 @@ func (c *JiraClient) GetTicket(ticketKey string) (*Ticket, error) {
 		ticket.Labels = issue.Fields.Labels
 	}
 
-	// Try to extract acceptance criteria from custom field if present
-	if issue.Fields.Unknowns != nil {
-		// Common custom field IDs for acceptance criteria
-		for _, fieldID := range []string{"customfield_10016", "customfield_10017", "customfield_10001"} {
-			if ac, ok := issue.Fields.Unknowns[fieldID]; ok {
-				if acStr, ok := ac.(string); ok && acStr != "" {
-					ticket.AcceptanceCrit = acStr
-					break
-				}
+	for _, c := range issue.Fields.Components {
+		ticket.Components = append(ticket.Components, c.Name)
+	}
+
+	for _, a := range issue.Fields.Attachments {
+		ticket.Attachments = append(ticket.Attachments, Attachment{
+			ID:       a.ID,
+			Filename: a.Filename,
+			MimeType: a.MimeType,
+			Size:     int64(a.Size),
+		})
+	}
+
+	if issue.Fields.Comments != nil {
+		for _, comment := range issue.Fields.Comments.Comments {
+			ticket.Comments = append(ticket.Comments, Comment{
+				Author:  comment.Author.DisplayName,
+				Created: formatJiraDate(comment.Created),
+				Body:    comment.Body,
+			})
+		}
+	}
+
+	for _, subtask := range issue.Fields.Subtasks {
+		st := Subtask{Key: subtask.Key, Summary: subtask.Fields.Summary}
+		if subtask.Fields.Status != nil {
+			st.Status = subtask.Fields.Status.Name
+		}
+		ticket.Subtasks = append(ticket.Subtasks, st)
+	}
+
+	for _, link := range issue.Fields.IssueLinks {
+		linked := LinkedIssue{Type: link.Type.Name}
+		other := link.InwardIssue
+		linked.Relation = link.Type.Inward
+		if link.OutwardIssue != nil {
+			other = link.OutwardIssue
+			linked.Relation = link.Type.Outward
+			linked.Outward = true
+		}
+		if other == nil {
+			continue
+		}
+		linked.Key = other.Key
+		if other.Fields != nil {
+			linked.Summary = other.Fields.Summary
+			if other.Fields.Status != nil {
+				linked.Status = other.Fields.Status.Name
 			}
 		}
+		ticket.Links = append(ticket.Links, linked)
 	}
 
-	return ticket, nil
+	// Without a field map for the project, guess at the acceptance criteria
+	ticket.AcceptanceCrit = guessAcceptanceCriteria(issue.Fields.Unknowns)
+
+	return ticket
+}
+
+// limitContext trims the ticket's links, subtasks and comments so their
+// combined text fits in maxChars. Comments are dropped oldest first.
+func limitContext(ticket *Ticket, maxChars int) {
+	budget := maxChars
+
+	links := ticket.Links[:0]
+	for _, link := range ticket.Links {
+		size := len(link.Key) + len(link.Summary) + len(link.Relation)
+		if size > budget {
+			break
+		}
+		budget -= size
+		links = append(links, link)
+	}
+	ticket.Links = links
+
+	subtasks := ticket.Subtasks[:0]
+	for _, st := range ticket.Subtasks {
+		size := len(st.Key) + len(st.Summary)
+		if size > budget {
+			break
+		}
+		budget -= size
+		subtasks = append(subtasks, st)
+	}
+	ticket.Subtasks = subtasks
+
+	// Walk back from the newest comment, keeping as many as fit. The newest
+	// comment is truncated rather than dropped if it alone is too long.
+	kept := 0
+	for i := len(ticket.Comments) - 1; i >= 0; i-- {
+		body := ticket.Comments[i].Body
+		if len(body) > budget {
+			if kept == 0 && budget > 0 {
+				ticket.Comments[i].Body = strings.ToValidUTF8(body[:budget], "") + "\n[truncated]"
+				kept++
+			}
+			break
+		}
+		budget -= len(body)
+		kept++
+	}
+	ticket.OmittedComments = len(ticket.Comments) - kept
+	ticket.Comments = ticket.Comments[len(ticket.Comments)-kept:]
+}
+
+// formatJiraDate shortens a Jira timestamp to its date, returning the input
+// unchanged if it cannot be parsed.
+func formatJiraDate(ts string) string {
+	t, err := time.Parse("2006-01-02T15:04:05.000-0700", ts)
+	if err != nil {
+		return ts
+	}
+	return t.Format("2006-01-02")
 }
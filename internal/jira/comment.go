@@ -0,0 +1,69 @@
+package jira
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RunReport describes the outcome of a work run on a ticket.
+type RunReport struct {
+	// Outcome is how the run ended, e.g. "PR created" or "failed", and
+	// Succeeded is whether that counts as a success.
+	Outcome      string
+	Succeeded    bool
+	PRURL        string
+	Branch       string
+	FilesChanged []string
+	Err          error
+}
+
+// FormatRunComment renders a run report as a Jira wiki markup comment.
+func FormatRunComment(r RunReport) string {
+	var sb strings.Builder
+
+	sb.WriteString("*jira-claude run summary*\n\n")
+
+	icon := "(!)"
+	switch {
+	case r.Err != nil:
+		icon = "(x)"
+	case r.Succeeded:
+		icon = "(/)"
+	}
+	sb.WriteString(fmt.Sprintf("*Outcome:* %s %s\n", icon, r.Outcome))
+	if r.Err != nil {
+		sb.WriteString(fmt.Sprintf("{noformat}%s{noformat}\n", r.Err.Error()))
+	}
+
+	if r.PRURL != "" {
+		sb.WriteString(fmt.Sprintf("*Pull request:* [%s|%s]\n", r.PRURL, r.PRURL))
+	} else {
+		sb.WriteString("*Pull request:* none created\n")
+	}
+
+	if r.Branch != "" {
+		sb.WriteString(fmt.Sprintf("*Branch:* {{%s}}\n", r.Branch))
+	}
+
+	if len(r.FilesChanged) > 0 {
+		sb.WriteString(fmt.Sprintf("\n*Files changed (%d):*\n", len(r.FilesChanged)))
+		for _, file := range r.FilesChanged {
+			sb.WriteString(fmt.Sprintf("* {{%s}}\n", file))
+		}
+	}
+
+	return sb.String()
+}
+
+// FormatPlanComment renders an implementation plan as a Jira wiki markup
+// comment. The plan is Markdown, so it is posted verbatim in a noformat block.
+func FormatPlanComment(plan string) string {
+	var sb strings.Builder
+
+	sb.WriteString("*jira-claude implementation plan*\n\n")
+	sb.WriteString("{noformat}\n")
+	sb.WriteString(strings.TrimSpace(plan))
+	sb.WriteString("\n{noformat}\n")
+
+	return sb.String()
+}
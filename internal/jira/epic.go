@@ -0,0 +1,93 @@
+package jira
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EpicChildrenJQL returns a JQL query for the epic's unfinished child issues.
+// Team-managed projects link children through the parent field; older
+// company-managed projects use the Epic Link custom field, which is also
+// matched when its field ID is given.
+func EpicChildrenJQL(epicKey, epicLinkField string) string {
+	match := fmt.Sprintf("parent = %s", epicKey)
+	if epicLinkField != "" {
+		id := strings.TrimPrefix(epicLinkField, "customfield_")
+		match = fmt.Sprintf("(parent = %s OR cf[%s] = %s)", epicKey, id, epicKey)
+	}
+	return match + " AND statusCategory != Done ORDER BY key ASC"
+}
+
+// OrderByDependencies sorts tickets so that each comes after the tickets
+// that block it. Only "Blocks" links between the given tickets count; apart
+// from that the input order is kept. Blocking cycles are reported as errors.
+func OrderByDependencies(tickets []*Ticket) ([]*Ticket, error) {
+	index := make(map[string]int, len(tickets))
+	for i, t := range tickets {
+		index[t.Key] = i
+	}
+
+	// blockers[i] holds the indexes of the tickets that must go before i.
+	blockers := make([]map[int]bool, len(tickets))
+	addBlocker := func(blocked, blocker int) {
+		if blockers[blocked] == nil {
+			blockers[blocked] = make(map[int]bool)
+		}
+		blockers[blocked][blocker] = true
+	}
+
+	for i, t := range tickets {
+		for _, link := range t.Links {
+			if !strings.EqualFold(link.Type, "Blocks") {
+				continue
+			}
+			j, ok := index[link.Key]
+			if !ok || j == i {
+				continue
+			}
+			if link.Outward {
+				addBlocker(j, i)
+			} else {
+				addBlocker(i, j)
+			}
+		}
+	}
+
+	ordered := make([]*Ticket, 0, len(tickets))
+	done := make([]bool, len(tickets))
+
+	for len(ordered) < len(tickets) {
+		next := -1
+		for i := range tickets {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for b := range blockers[i] {
+				if !done[b] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+
+		if next == -1 {
+			var stuck []string
+			for i, t := range tickets {
+				if !done[i] {
+					stuck = append(stuck, t.Key)
+				}
+			}
+			return nil, fmt.Errorf("blocking links form a cycle between %s", strings.Join(stuck, ", "))
+		}
+
+		done[next] = true
+		ordered = append(ordered, tickets[next])
+	}
+
+	return ordered, nil
+}
@@ -0,0 +1,156 @@
+package jira
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEpicChildrenJQL(t *testing.T) {
+	tests := []struct {
+		name          string
+		epicLinkField string
+		want          string
+	}{
+		{
+			name: "parent only",
+			want: "parent = SUI-500 AND statusCategory != Done ORDER BY key ASC",
+		},
+		{
+			name:          "epic link field",
+			epicLinkField: "customfield_10014",
+			want:          "(parent = SUI-500 OR cf[10014] = SUI-500) AND statusCategory != Done ORDER BY key ASC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EpicChildrenJQL("SUI-500", tt.epicLinkField); got != tt.want {
+				t.Errorf("EpicChildrenJQL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// blocks returns an outward "blocks" link to key.
+func blocks(key string) LinkedIssue {
+	return LinkedIssue{Key: key, Type: "Blocks", Relation: "blocks", Outward: true}
+}
+
+// blockedBy returns an inward "is blocked by" link to key.
+func blockedBy(key string) LinkedIssue {
+	return LinkedIssue{Key: key, Type: "Blocks", Relation: "is blocked by"}
+}
+
+func TestOrderByDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		tickets []*Ticket
+		want    string
+		wantErr string
+	}{
+		{
+			name: "no links keeps input order",
+			tickets: []*Ticket{
+				{Key: "A-1"}, {Key: "A-2"}, {Key: "A-3"},
+			},
+			want: "A-1,A-2,A-3",
+		},
+		{
+			name: "outward blocks link",
+			tickets: []*Ticket{
+				{Key: "A-1"},
+				{Key: "A-2", Links: []LinkedIssue{blocks("A-1")}},
+			},
+			want: "A-2,A-1",
+		},
+		{
+			name: "inward is blocked by link",
+			tickets: []*Ticket{
+				{Key: "A-1", Links: []LinkedIssue{blockedBy("A-3")}},
+				{Key: "A-2"},
+				{Key: "A-3"},
+			},
+			want: "A-2,A-3,A-1",
+		},
+		{
+			name: "both sides of the same link",
+			tickets: []*Ticket{
+				{Key: "A-1", Links: []LinkedIssue{blockedBy("A-2")}},
+				{Key: "A-2", Links: []LinkedIssue{blocks("A-1")}},
+			},
+			want: "A-2,A-1",
+		},
+		{
+			name: "chain",
+			tickets: []*Ticket{
+				{Key: "A-1", Links: []LinkedIssue{blockedBy("A-2")}},
+				{Key: "A-2", Links: []LinkedIssue{blockedBy("A-3")}},
+				{Key: "A-3"},
+			},
+			want: "A-3,A-2,A-1",
+		},
+		{
+			name: "links outside the epic are ignored",
+			tickets: []*Ticket{
+				{Key: "A-1", Links: []LinkedIssue{blockedBy("B-9")}},
+				{Key: "A-2", Links: []LinkedIssue{blocks("B-9")}},
+			},
+			want: "A-1,A-2",
+		},
+		{
+			name: "other link types are ignored",
+			tickets: []*Ticket{
+				{Key: "A-1", Links: []LinkedIssue{{Key: "A-2", Type: "Relates", Relation: "relates to"}}},
+				{Key: "A-2", Links: []LinkedIssue{{Key: "A-1", Type: "Duplicate", Relation: "duplicates", Outward: true}}},
+			},
+			want: "A-1,A-2",
+		},
+		{
+			name: "link type matched case-insensitively",
+			tickets: []*Ticket{
+				{Key: "A-1"},
+				{Key: "A-2", Links: []LinkedIssue{{Key: "A-1", Type: "blocks", Outward: true}}},
+			},
+			want: "A-2,A-1",
+		},
+		{
+			name: "self link is ignored",
+			tickets: []*Ticket{
+				{Key: "A-1", Links: []LinkedIssue{blocks("A-1")}},
+			},
+			want: "A-1",
+		},
+		{
+			name: "cycle",
+			tickets: []*Ticket{
+				{Key: "A-1"},
+				{Key: "A-2", Links: []LinkedIssue{blocks("A-3")}},
+				{Key: "A-3", Links: []LinkedIssue{blocks("A-2")}},
+			},
+			wantErr: "blocking links form a cycle between A-2, A-3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered, err := OrderByDependencies(tt.tickets)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("OrderByDependencies() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OrderByDependencies() error = %v", err)
+			}
+
+			keys := make([]string, len(ordered))
+			for i, ticket := range ordered {
+				keys[i] = ticket.Key
+			}
+			if got := strings.Join(keys, ","); got != tt.want {
+				t.Errorf("OrderByDependencies() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	pkgerrors "github.com/pkg/errors"
+)
+
+// FieldMap names the custom fields that hold well-known ticket data in a
+// project. Extra maps a label shown in the prompt to a field ID.
+type FieldMap struct {
+	AcceptanceCriteria string            `json:"acceptance_criteria" yaml:"acceptance_criteria"`
+	StoryPoints        string            `json:"story_points" yaml:"story_points"`
+	EpicLink           string            `json:"epic_link" yaml:"epic_link"`
+	Sprint             string            `json:"sprint" yaml:"sprint"`
+	Extra              map[string]string `json:"extra" yaml:"extra"`
+}
+
+// CustomField is an extra field value included in the prompt.
+type CustomField struct {
+	Name  string
+	Value string
+}
+
+// Field describes a Jira field. IssueTypes lists the issue types the field
+// is available on when the fields were listed for a project.
+type Field struct {
+	ID         string
+	Name       string
+	Type       string
+	Custom     bool
+	IssueTypes []string
+}
+
+// legacyAcceptanceCritFields are the custom field IDs that were guessed to hold
+// acceptance criteria before field maps existed. They are only consulted for
+// projects without a field map.
+var legacyAcceptanceCritFields = []string{"customfield_10016", "customfield_10017", "customfield_10001"}
+
+// ListFields returns the fields defined in Jira. With a project key, only the
+// fields available on that project's issue types are returned.
+func (c *JiraClient) ListFields(projectKey string) ([]Field, error) {
+	all, _, err := c.client.Field.GetList()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to list fields")
+	}
+
+	var issueTypes map[string][]string
+	if projectKey != "" {
+		meta, _, err := c.client.Issue.GetCreateMetaWithOptions(&jira.GetQueryOptions{
+			ProjectKeys: projectKey,
+			Expand:      "projects.issuetypes.fields",
+		})
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "failed to get field metadata for project %s", projectKey)
+		}
+		project := meta.GetProjectWithKey(projectKey)
+		if project == nil {
+			return nil, fmt.Errorf("project %s not found or not visible", projectKey)
+		}
+
+		issueTypes = make(map[string][]string)
+		for _, it := range project.IssueTypes {
+			for id := range it.Fields {
+				issueTypes[id] = append(issueTypes[id], it.Name)
+			}
+		}
+	}
+
+	fields := make([]Field, 0, len(all))
+	for _, f := range all {
+		field := Field{ID: f.ID, Name: f.Name, Type: f.Schema.Type, Custom: f.Custom}
+		if issueTypes != nil {
+			types, ok := issueTypes[f.ID]
+			if !ok {
+				continue
+			}
+			sort.Strings(types)
+			field.IssueTypes = types
+		}
+		fields = append(fields, field)
+	}
+
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields, nil
+}
+
+// applyFieldMap fills the ticket's mapped fields from the issue's custom
+// fields, replacing the legacy acceptance criteria guess.
+func applyFieldMap(ticket *Ticket, unknowns map[string]any, fm *FieldMap) {
+	ticket.AcceptanceCrit = ""
+	if fm.AcceptanceCriteria != "" {
+		ticket.AcceptanceCrit = customFieldText(unknowns[fm.AcceptanceCriteria])
+	}
+	if fm.StoryPoints != "" {
+		ticket.StoryPoints = customFieldValue(unknowns[fm.StoryPoints])
+	}
+	if fm.EpicLink != "" {
+		ticket.EpicKey = customFieldValue(unknowns[fm.EpicLink])
+	}
+	if fm.Sprint != "" {
+		ticket.Sprint = sprintName(unknowns[fm.Sprint])
+	}
+
+	labels := make([]string, 0, len(fm.Extra))
+	for label := range fm.Extra {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		value := customFieldText(unknowns[fm.Extra[label]])
+		if value == "" {
+			value = customFieldValue(unknowns[fm.Extra[label]])
+		}
+		if value != "" {
+			ticket.ExtraFields = append(ticket.ExtraFields, CustomField{Name: label, Value: value})
+		}
+	}
+}
+
+// guessAcceptanceCriteria looks for acceptance criteria in the legacy
+// hardcoded custom fields.
+func guessAcceptanceCriteria(unknowns map[string]any) string {
+	for _, fieldID := range legacyAcceptanceCritFields {
+		if ac := customFieldText(unknowns[fieldID]); ac != "" {
+			return ac
+		}
+	}
+	return ""
+}
+
+// customFieldText returns the raw text of a rich-text custom field. Fields
+// holding an ADF document are returned as their JSON so the prompt renderer
+// can convert them to Markdown.
+func customFieldText(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]any:
+		if v["type"] != "doc" {
+			return ""
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(raw)
+	default:
+		return ""
+	}
+}
+
+// customFieldValue renders a scalar, option, user or list custom field value
+// as plain text.
+func customFieldValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]any:
+		for _, key := range []string{"value", "name", "displayName", "key"} {
+			if s, ok := v[key].(string); ok && s != "" {
+				return s
+			}
+		}
+		return ""
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := customFieldValue(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sprintName returns the name of the most recent sprint in a sprint field.
+// Older Jira versions return sprints as serialized strings such as
+// "com.atlassian.greenhopper.service.sprint.Sprint@1a2b[id=1,name=Sprint 4,...]".
+func sprintName(value any) string {
+	sprints, ok := value.([]any)
+	if !ok || len(sprints) == 0 {
+		return customFieldValue(value)
+	}
+
+	switch s := sprints[len(sprints)-1].(type) {
+	case map[string]any:
+		return customFieldValue(s)
+	case string:
+		for _, part := range strings.Split(s, ",") {
+			if name, ok := strings.CutPrefix(part, "name="); ok {
+				return name
+			}
+		}
+		return s
+	default:
+		return customFieldValue(s)
+	}
+}
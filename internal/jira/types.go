@@ -2,53 +2,99 @@ package jira
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+
+	"github.com/bsaliba1/jira-claude/internal/markup"
 )
 
+var ticketKeyPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9_]+-[0-9]+\b`)
+
 type Ticket struct {
-	Key             string
-	Summary         string
-	Description     string
-	AcceptanceCrit  string
-	IssueType       string
-	Priority        string
-	Labels          []string
-	ProjectKey      string
+	Key            string
+	Summary        string
+	Description    string
+	AcceptanceCrit string
+	IssueType      string
+	Status         string
+	Priority       string
+	Labels         []string
+	Components     []string
+	ProjectKey     string
+	StoryPoints    string
+	EpicKey        string
+	Sprint         string
+	ExtraFields    []CustomField
+	Attachments    []Attachment
+	Comments       []Comment
+	Subtasks       []Subtask
+	Links          []LinkedIssue
+
+	// OmittedComments counts older comments dropped to stay within the
+	// context limit.
+	OmittedComments int
 }
 
-func (t *Ticket) FormatAsPrompt(promptPrefix string) string {
-	var sb strings.Builder
+// Comment is a comment from the ticket's discussion thread.
+type Comment struct {
+	Author  string
+	Created string
+	Body    string
+}
 
-	if promptPrefix != "" {
-		sb.WriteString(promptPrefix)
-		sb.WriteString("\n\n")
-	}
+// Subtask is a summary of one of the ticket's subtasks.
+type Subtask struct {
+	Key     string
+	Summary string
+	Status  string
+}
 
-	sb.WriteString(fmt.Sprintf("# Jira Ticket: %s\n\n", t.Key))
-	sb.WriteString(fmt.Sprintf("## Summary\n%s\n\n", t.Summary))
+// LinkedIssue is an issue linked to the ticket. Type is the link type name
+// (e.g. "Blocks") and Relation describes the link from this ticket's side
+// (e.g. "is blocked by").
+type LinkedIssue struct {
+	Key      string
+	Summary  string
+	Status   string
+	Type     string
+	Relation string
+	Outward  bool
+}
 
-	if t.Description != "" {
-		sb.WriteString(fmt.Sprintf("## Description\n%s\n\n", t.Description))
-	}
+// Attachment is a file attached to a ticket. Path is set once the file has
+// been downloaded; only downloaded attachments are listed in the prompt.
+type Attachment struct {
+	ID       string
+	Filename string
+	MimeType string
+	Size     int64
+	Path     string
+}
 
-	if t.AcceptanceCrit != "" {
-		sb.WriteString(fmt.Sprintf("## Acceptance Criteria\n%s\n\n", t.AcceptanceCrit))
-	}
+// FormatAsEpicContext renders the ticket as shared context for the prompts
+// of its child issues.
+func (t *Ticket) FormatAsEpicContext() string {
+	var sb strings.Builder
 
-	if t.IssueType != "" {
-		sb.WriteString(fmt.Sprintf("**Type:** %s\n", t.IssueType))
-	}
+	sb.WriteString(fmt.Sprintf("# Epic %s: %s\n\n", t.Key, t.Summary))
+	sb.WriteString("The ticket below is part of this epic. Use the epic as background context, but only implement the ticket itself.\n")
 
-	if t.Priority != "" {
-		sb.WriteString(fmt.Sprintf("**Priority:** %s\n", t.Priority))
+	if description := markup.ToMarkdown(t.Description); description != "" {
+		sb.WriteString(fmt.Sprintf("\n%s\n", description))
 	}
 
-	if len(t.Labels) > 0 {
-		sb.WriteString(fmt.Sprintf("**Labels:** %s\n", strings.Join(t.Labels, ", ")))
-	}
+	return sb.String()
+}
 
-	sb.WriteString("\n---\n\n")
-	sb.WriteString("Please implement this ticket. Follow best practices and existing code patterns in the repository.")
+// ProjectKeyOf returns the project part of a ticket key, e.g. "SUI" for
+// "SUI-640".
+func ProjectKeyOf(ticketKey string) string {
+	project, _, _ := strings.Cut(ticketKey, "-")
+	return project
+}
 
-	return sb.String()
+// TicketKeyIn returns the first ticket key in s, e.g. "SUI-640" for the PR
+// title "SUI-640: Add widgets", or "" if there is none.
+func TicketKeyIn(s string) string {
+	return ticketKeyPattern.FindString(s)
 }
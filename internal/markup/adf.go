@@ -0,0 +1,318 @@
+package markup
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// adfNode is a node in an Atlassian Document Format tree.
+type adfNode struct {
+	Type    string         `json:"type"`
+	Text    string         `json:"text"`
+	Attrs   map[string]any `json:"attrs"`
+	Marks   []adfMark      `json:"marks"`
+	Content []adfNode      `json:"content"`
+}
+
+type adfMark struct {
+	Type  string         `json:"type"`
+	Attrs map[string]any `json:"attrs"`
+}
+
+// ADFToMarkdown converts an Atlassian Document Format JSON document to
+// Markdown.
+func ADFToMarkdown(doc []byte) (string, error) {
+	var root adfNode
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return "", pkgerrors.Wrap(err, "failed to parse ADF document")
+	}
+	if root.Type != "doc" {
+		return "", fmt.Errorf("not an ADF document (root type %q)", root.Type)
+	}
+
+	return tidy(strings.Join(renderADFBlocks(root.Content), "\n")), nil
+}
+
+// renderADFBlocks renders block nodes separated by blank lines.
+func renderADFBlocks(nodes []adfNode) []string {
+	var lines []string
+	for _, n := range nodes {
+		block := renderADFBlock(n)
+		if len(block) == 0 {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, block...)
+	}
+	return lines
+}
+
+func renderADFBlock(n adfNode) []string {
+	switch n.Type {
+	case "paragraph":
+		text := renderADFInline(n.Content)
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return strings.Split(text, "\n")
+
+	case "heading":
+		level := min(max(adfIntAttr(n.Attrs, "level", 1), 1), 6)
+		return []string{strings.Repeat("#", level) + " " + renderADFInline(n.Content)}
+
+	case "bulletList":
+		return renderADFList(n.Content, func(int, adfNode) string { return "-" })
+
+	case "orderedList":
+		start := adfIntAttr(n.Attrs, "order", 1)
+		return renderADFList(n.Content, func(i int, _ adfNode) string { return fmt.Sprintf("%d.", start+i) })
+
+	case "taskList":
+		return renderADFList(n.Content, func(_ int, item adfNode) string {
+			if adfStringAttr(item.Attrs, "state") == "DONE" {
+				return "- [x]"
+			}
+			return "- [ ]"
+		})
+
+	case "decisionList":
+		return renderADFList(n.Content, func(int, adfNode) string { return "- Decision:" })
+
+	case "codeBlock":
+		lines := []string{"```" + adfStringAttr(n.Attrs, "language")}
+		lines = append(lines, strings.Split(adfPlainText(n.Content), "\n")...)
+		return append(lines, "```")
+
+	case "blockquote":
+		return quoteLines(renderADFBlocks(n.Content))
+
+	case "panel":
+		var inner []string
+		if label := panelLabel(adfStringAttr(n.Attrs, "panelType"), ""); label != "" {
+			inner = append(inner, label, "")
+		}
+		inner = append(inner, renderADFBlocks(n.Content)...)
+		return quoteLines(inner)
+
+	case "expand", "nestedExpand":
+		var lines []string
+		if title := adfStringAttr(n.Attrs, "title"); title != "" {
+			lines = append(lines, "**"+title+"**", "")
+		}
+		return append(lines, renderADFBlocks(n.Content)...)
+
+	case "rule":
+		return []string{"---"}
+
+	case "table":
+		var rows [][]string
+		for _, row := range n.Content {
+			var cells []string
+			for _, cell := range row.Content {
+				cells = append(cells, adfCellText(cell.Content))
+			}
+			rows = append(rows, cells)
+		}
+		return tableLines(rows)
+
+	case "mediaSingle", "mediaGroup":
+		var lines []string
+		for _, media := range n.Content {
+			lines = append(lines, renderADFBlock(media)...)
+		}
+		return lines
+
+	case "media":
+		name := adfStringAttr(n.Attrs, "alt")
+		if name == "" {
+			name = adfStringAttr(n.Attrs, "id")
+		}
+		return []string{fmt.Sprintf("[attachment: %s]", name)}
+
+	case "blockCard", "embedCard":
+		return []string{"<" + adfStringAttr(n.Attrs, "url") + ">"}
+
+	default:
+		if len(n.Content) > 0 {
+			return renderADFBlocks(n.Content)
+		}
+		if n.Text != "" {
+			return strings.Split(n.Text, "\n")
+		}
+		return nil
+	}
+}
+
+// adfCellText renders a table cell on one line, with <br> between its lines
+// as for wiki tables.
+func adfCellText(content []adfNode) string {
+	var lines []string
+	for _, line := range renderADFBlocks(content) {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "<br>")
+}
+
+// renderADFList renders list items, indenting continuation lines and nested
+// lists under the item's marker.
+func renderADFList(items []adfNode, marker func(int, adfNode) string) []string {
+	var lines []string
+	for i, item := range items {
+		var body []string
+		if item.Type == "taskItem" || item.Type == "decisionItem" {
+			// Task and decision items hold inline content directly.
+			body = strings.Split(renderADFInline(item.Content), "\n")
+		} else {
+			for _, child := range item.Content {
+				body = append(body, renderADFBlock(child)...)
+			}
+		}
+		if len(body) == 0 {
+			body = []string{""}
+		}
+
+		m := marker(i, item)
+		indent := strings.Repeat(" ", len(m)+1)
+		lines = append(lines, m+" "+body[0])
+		for _, line := range body[1:] {
+			if line == "" {
+				lines = append(lines, "")
+			} else {
+				lines = append(lines, indent+line)
+			}
+		}
+	}
+	return lines
+}
+
+// renderADFInline renders inline nodes (text, mentions, links, ...) as a
+// single Markdown string. Hard breaks become newlines.
+func renderADFInline(nodes []adfNode) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		switch n.Type {
+		case "text":
+			sb.WriteString(applyADFMarks(n.Text, n.Marks))
+		case "hardBreak":
+			sb.WriteString("\n")
+		case "mention":
+			text := adfStringAttr(n.Attrs, "text")
+			if text == "" {
+				text = adfStringAttr(n.Attrs, "id")
+			}
+			if !strings.HasPrefix(text, "@") {
+				text = "@" + text
+			}
+			sb.WriteString(text)
+		case "emoji":
+			text := adfStringAttr(n.Attrs, "text")
+			if text == "" {
+				text = adfStringAttr(n.Attrs, "shortName")
+			}
+			sb.WriteString(text)
+		case "inlineCard":
+			sb.WriteString("<" + adfStringAttr(n.Attrs, "url") + ">")
+		case "date":
+			sb.WriteString(adfDate(n.Attrs))
+		case "status":
+			sb.WriteString("[" + adfStringAttr(n.Attrs, "text") + "]")
+		default:
+			if len(n.Content) > 0 {
+				sb.WriteString(renderADFInline(n.Content))
+			} else {
+				sb.WriteString(n.Text)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// applyADFMarks wraps text in the Markdown equivalent of its marks. Leading
+// and trailing whitespace is kept outside the markers, since Markdown does
+// not read "**hi **" as emphasis.
+func applyADFMarks(text string, marks []adfMark) string {
+	var href string
+	for _, m := range marks {
+		if m.Type == "code" {
+			text = "`" + text + "`"
+		}
+	}
+
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	leading := text[:len(text)-len(trimmed)]
+	text = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	trailing := trimmed[len(text):]
+	if text == "" {
+		return leading
+	}
+
+	for _, m := range marks {
+		switch m.Type {
+		case "strong":
+			text = "**" + text + "**"
+		case "em":
+			text = "_" + text + "_"
+		case "strike":
+			text = "~~" + text + "~~"
+		case "link":
+			href = adfStringAttr(m.Attrs, "href")
+		}
+	}
+	if href != "" {
+		text = "[" + text + "](" + href + ")"
+	}
+	return leading + text + trailing
+}
+
+// adfPlainText concatenates the text of nodes without any formatting.
+func adfPlainText(nodes []adfNode) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		if n.Type == "hardBreak" {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(n.Text)
+		sb.WriteString(adfPlainText(n.Content))
+	}
+	return sb.String()
+}
+
+// adfDate formats a date node's timestamp, given in epoch milliseconds.
+func adfDate(attrs map[string]any) string {
+	var ms int64
+	switch v := attrs["timestamp"].(type) {
+	case string:
+		ms, _ = strconv.ParseInt(v, 10, 64)
+	case float64:
+		ms = int64(v)
+	}
+	if ms == 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).UTC().Format("2006-01-02")
+}
+
+func adfStringAttr(attrs map[string]any, key string) string {
+	if v, ok := attrs[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func adfIntAttr(attrs map[string]any, key string, fallback int) int {
+	if v, ok := attrs[key].(float64); ok {
+		return int(v)
+	}
+	return fallback
+}
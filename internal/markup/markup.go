@@ -0,0 +1,106 @@
+// Package markup converts the rich-text formats Jira returns into Markdown.
+//
+// Jira's v2 REST API returns descriptions and text custom fields as wiki
+// markup, while v3 and some custom fields use the Atlassian Document Format
+// (ADF), a JSON document tree. Claude reads Markdown best, so both are
+// normalized before they are put into a prompt.
+package markup
+
+import (
+	"regexp"
+	"strings"
+)
+
+var blankRuns = regexp.MustCompile(`\n{3,}`)
+
+// ToMarkdown converts Jira rich text to Markdown, detecting whether the input
+// is an ADF document or wiki markup.
+func ToMarkdown(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return ""
+	}
+
+	if strings.HasPrefix(trimmed, "{\"") {
+		if md, err := ADFToMarkdown([]byte(trimmed)); err == nil {
+			return md
+		}
+	}
+
+	return WikiToMarkdown(s)
+}
+
+// tidy trims trailing whitespace from lines and collapses runs of blank lines.
+func tidy(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	s = strings.Join(lines, "\n")
+	s = blankRuns.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
+
+// quoteLines prefixes each line with a Markdown blockquote marker.
+func quoteLines(lines []string) []string {
+	quoted := make([]string, len(lines))
+	for i, line := range lines {
+		if line == "" {
+			quoted[i] = ">"
+		} else {
+			quoted[i] = "> " + line
+		}
+	}
+	return quoted
+}
+
+// panelLabel returns the bold heading used when a panel is rendered as a
+// blockquote, e.g. "**Warning:** Title".
+func panelLabel(kind, title string) string {
+	var label string
+	if kind != "" && kind != "panel" {
+		label = "**" + strings.ToUpper(kind[:1]) + kind[1:] + ":**"
+	}
+	if title != "" {
+		if label != "" {
+			return label + " " + title
+		}
+		return "**" + title + "**"
+	}
+	return label
+}
+
+// tableLines renders rows as a Markdown table, using the first row as the
+// header since Markdown tables always need one.
+func tableLines(rows [][]string) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+
+	format := func(row []string) string {
+		cells := make([]string, width)
+		for i := range cells {
+			if i < len(row) {
+				cells[i] = strings.ReplaceAll(strings.TrimSpace(row[i]), "|", "\\|")
+			}
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+
+	lines := []string{format(rows[0])}
+	sep := make([]string, width)
+	for i := range sep {
+		sep[i] = "---"
+	}
+	lines = append(lines, "| "+strings.Join(sep, " | ")+" |")
+	for _, row := range rows[1:] {
+		lines = append(lines, format(row))
+	}
+
+	return lines
+}
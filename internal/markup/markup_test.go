@@ -0,0 +1,225 @@
+package markup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWikiToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "code block with language",
+			in:   "Run:\n{code:java}\nint x = 1;\n{code}",
+			want: "Run:\n\n```java\nint x = 1;\n```",
+		},
+		{
+			name: "inline code block",
+			in:   "Intro {code:language=go}x := 1{code} after",
+			want: "Intro\n```go\nx := 1\n```\nafter",
+		},
+		{
+			name: "macro tags inside code are kept",
+			in:   "{code}\ns := \"{panel}\" + \"{noformat}x{noformat}\"\n{code}",
+			want: "```\ns := \"{panel}\" + \"{noformat}x{noformat}\"\n```",
+		},
+		{
+			name: "noformat body is not converted",
+			in:   "{noformat}\n*not bold* {code} [~jdoe]\n{noformat}",
+			want: "```\n*not bold* {code} [~jdoe]\n```",
+		},
+		{
+			name: "noformat line inside code is literal",
+			in:   "{code}\nfoo\n{noformat}\nbar\n{code}\nafter *bold*",
+			want: "```\nfoo\n{noformat}\nbar\n```\n\nafter **bold**",
+		},
+		{
+			name: "panel closing tag inside nested code",
+			in:   "{panel:title=Setup}\nRun this:\n{code}\n{panel}\n{code}\nDone\n{panel}\nAfter",
+			want: "> **Setup**\n>\n> Run this:\n>\n> ```\n> {panel}\n> ```\n>\n> Done\n\nAfter",
+		},
+		{
+			name: "info panel",
+			in:   "{warning:title=Careful}Do *not* do this{warning}",
+			want: "> **Warning:** Careful\n>\n> Do **not** do this",
+		},
+		{
+			name: "quote",
+			in:   "{quote}\nsaid *this*\n{quote}",
+			want: "> said **this**",
+		},
+		{
+			name: "table",
+			in:   "||Name||Link||\n|docs|[Docs|https://example.com]|\n|owner|[~jdoe]|",
+			want: "| Name | Link |\n| --- | --- |\n| docs | [Docs](https://example.com) |\n| owner | @jdoe |",
+		},
+		{
+			name: "line break in table cell",
+			in:   "||Step||Notes||\n|1|first\\\\second|\n|2|done|",
+			want: "| Step | Notes |\n| --- | --- |\n| 1 | first<br>second |\n| 2 | done |",
+		},
+		{
+			name: "nested lists",
+			in:   "* a\n** b\n# c\n#* d",
+			want: "- a\n  - b\n1. c\n   - d",
+		},
+		{
+			name: "mentions",
+			in:   "cc [~jdoe] and [~accountid:5b10ac8d82e05b22cc7d4ef5]",
+			want: "cc @jdoe and @5b10ac8d82e05b22cc7d4ef5",
+		},
+		{
+			name: "links",
+			in:   "See [Docs|https://example.com], [https://example.com/x] and [^log.txt]",
+			want: "See [Docs](https://example.com), <https://example.com/x> and log.txt",
+		},
+		{
+			name: "headings, quotes and inline markup",
+			in:   "h2. Title\nbq. quoted\n*bold* _it_ -gone- {{mono *x*}}\n----\nend",
+			want: "## Title\n> quoted\n**bold** _it_ ~~gone~~ `mono *x*`\n\n---\n\nend",
+		},
+		{
+			name: "line break outside a table",
+			in:   "first\\\\second",
+			want: "first\nsecond",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WikiToMarkdown(tt.in); got != tt.want {
+				t.Errorf("WikiToMarkdown(%q) =\n%s\nwant:\n%s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// adfDoc wraps block nodes in an ADF document.
+func adfDoc(blocks ...string) string {
+	return `{"type":"doc","version":1,"content":[` + strings.Join(blocks, ",") + `]}`
+}
+
+// adfPara is a paragraph of inline nodes.
+func adfPara(inline ...string) string {
+	return `{"type":"paragraph","content":[` + strings.Join(inline, ",") + `]}`
+}
+
+// adfText is a text node.
+func adfText(text string) string {
+	return `{"type":"text","text":"` + text + `"}`
+}
+
+func TestADFToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "code block",
+			in:   adfDoc(`{"type":"codeBlock","attrs":{"language":"go"},"content":[{"type":"text","text":"x := 1\ny := \"*2*\""}]}`),
+			want: "```go\nx := 1\ny := \"*2*\"\n```",
+		},
+		{
+			name: "table",
+			in: adfDoc(`{"type":"table","content":[
+				{"type":"tableRow","content":[
+					{"type":"tableHeader","content":[` + adfPara(adfText("Name")) + `]},
+					{"type":"tableHeader","content":[` + adfPara(adfText("Notes")) + `]}]},
+				{"type":"tableRow","content":[
+					{"type":"tableCell","content":[` + adfPara(adfText("a|b")) + `]},
+					{"type":"tableCell","content":[` + adfPara(adfText("one")) + `,` + adfPara(adfText("two")) + `]}]},
+				{"type":"tableRow","content":[
+					{"type":"tableCell","content":[` + adfPara(adfText("c")) + `]},
+					{"type":"tableCell","content":[` + adfPara(adfText("three"), `{"type":"hardBreak"}`, adfText("four")) + `]}]}]}`),
+			want: "| Name | Notes |\n| --- | --- |\n| a\\|b | one<br>two |\n| c | three<br>four |",
+		},
+		{
+			name: "nested lists",
+			in: adfDoc(`{"type":"bulletList","content":[
+				{"type":"listItem","content":[` + adfPara(adfText("a")) + `,
+					{"type":"orderedList","attrs":{"order":3},"content":[
+						{"type":"listItem","content":[` + adfPara(adfText("b")) + `]},
+						{"type":"listItem","content":[` + adfPara(adfText("c")) + `]}]}]},
+				{"type":"listItem","content":[` + adfPara(adfText("d")) + `]}]}`),
+			want: "- a\n  3. b\n  4. c\n- d",
+		},
+		{
+			name: "task list",
+			in: adfDoc(`{"type":"taskList","content":[
+				{"type":"taskItem","attrs":{"state":"DONE"},"content":[` + adfText("shipped") + `]},
+				{"type":"taskItem","attrs":{"state":"TODO"},"content":[` + adfText("document") + `]}]}`),
+			want: "- [x] shipped\n- [ ] document",
+		},
+		{
+			name: "panel with nested code",
+			in: adfDoc(`{"type":"panel","attrs":{"panelType":"note"},"content":[` + adfPara(adfText("Run:")) + `,
+				{"type":"codeBlock","content":[{"type":"text","text":"make"}]}]}`),
+			want: "> **Note:**\n>\n> Run:\n>\n> ```\n> make\n> ```",
+		},
+		{
+			name: "mentions",
+			in: adfDoc(adfPara(adfText("cc "),
+				`{"type":"mention","attrs":{"id":"5b10","text":"@Jane Doe"}}`, adfText(" and "),
+				`{"type":"mention","attrs":{"id":"5b11"}}`)),
+			want: "cc @Jane Doe and @5b11",
+		},
+		{
+			name: "links",
+			in: adfDoc(adfPara(adfText("See "),
+				`{"type":"text","text":"docs","marks":[{"type":"link","attrs":{"href":"https://example.com"}},{"type":"strong"}]}`, adfText(" and "),
+				`{"type":"inlineCard","attrs":{"url":"https://example.com/x"}}`)),
+			want: "See [**docs**](https://example.com) and <https://example.com/x>",
+		},
+		{
+			name: "headings and marks",
+			in: adfDoc(`{"type":"heading","attrs":{"level":2},"content":[`+adfText("Title")+`]}`,
+				adfPara(`{"type":"text","text":"code","marks":[{"type":"code"}]}`, adfText(" "),
+					`{"type":"text","text":"it","marks":[{"type":"em"}]}`, `{"type":"hardBreak"}`, adfText("next"))),
+			want: "## Title\n\n`code` _it_\nnext",
+		},
+		{
+			name: "whitespace kept outside marks",
+			in: adfDoc(adfPara(adfText("say"), `{"type":"text","text":" hi ","marks":[{"type":"strong"}]}`,
+				`{"type":"text","text":"there ","marks":[{"type":"em"}]}`, `{"type":"text","text":" ","marks":[{"type":"strong"}]}`,
+				adfText("now"))),
+			want: "say **hi** _there_  now",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ADFToMarkdown([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("ADFToMarkdown: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ADFToMarkdown =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "empty", in: "  \n", want: ""},
+		{name: "ADF", in: adfDoc(adfPara(adfText("hello"))), want: "hello"},
+		{name: "wiki", in: "h1. Hello", want: "# Hello"},
+		{name: "JSON that is not ADF", in: `{"type":"other"}`, want: `{"type":"other"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToMarkdown(tt.in); got != tt.want {
+				t.Errorf("ToMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Block macros are moved onto their own lines before conversion so the
+	// line-based parser can find where they open and close.
+	wikiMacroTag  = regexp.MustCompile(`\{(code|noformat|panel|quote|info|note|warning|tip)(:[^}]*)?\}`)
+	wikiMacroLine = regexp.MustCompile(`^\{(code|noformat|panel|quote|info|note|warning|tip)(?::([^}]*))?\}$`)
+
+	wikiHeading = regexp.MustCompile(`^h([1-6])\.\s+(.*)$`)
+	wikiQuote   = regexp.MustCompile(`^bq\.\s+(.*)$`)
+	wikiList    = regexp.MustCompile(`^([*#]+|-)\s+(.*)$`)
+	wikiRule    = regexp.MustCompile(`^-{4,}$`)
+
+	wikiMono      = regexp.MustCompile(`\{\{(.+?)\}\}`)
+	wikiColor     = regexp.MustCompile(`\{color(:[^}]*)?\}`)
+	wikiAnchor    = regexp.MustCompile(`\{anchor:[^}]*\}`)
+	wikiMention   = regexp.MustCompile(`\[~(?:accountid:)?([^\]]+)\]`)
+	wikiLinkText  = regexp.MustCompile(`\[([^\]|]+)\|([^\]|]+)(?:\|[^\]]*)?\]`)
+	wikiLinkBare  = regexp.MustCompile(`\[((?:https?|mailto|ftp):[^\]\s]+)\]`)
+	wikiLinkFile  = regexp.MustCompile(`\[\^([^\]]+)\]`)
+	wikiImage     = regexp.MustCompile(`!([^\s!|]+\.[A-Za-z0-9]+|https?://[^\s!|]+)(?:\|[^!]*)?!`)
+	wikiBold      = regexp.MustCompile(`(^|[^\w*])\*([^*\s](?:[^*]*[^*\s])?)\*([^\w*]|$)`)
+	wikiStrike    = regexp.MustCompile(`(^|[^\w-])-([^-\s](?:[^-]*[^-\s])?)-([^\w-]|$)`)
+	wikiUnderline = regexp.MustCompile(`(^|[^\w+])\+([^+\s](?:[^+]*[^+\s])?)\+([^\w+]|$)`)
+	wikiCitation  = regexp.MustCompile(`\?\?([^?]+)\?\?`)
+	wikiCodeSpan  = regexp.MustCompile("`[^`]*`")
+)
+
+// WikiToMarkdown converts Jira wiki markup to Markdown.
+func WikiToMarkdown(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = splitWikiMacros(s)
+	return tidy(strings.Join(convertWikiBlocks(strings.Split(s, "\n")), "\n"))
+}
+
+// splitWikiMacros moves block macro tags onto their own lines. Code and
+// noformat bodies are left as they are, so tags quoted inside them stay
+// part of the code.
+func splitWikiMacros(s string) string {
+	var sb strings.Builder
+	var verbatim string // the code or noformat macro being copied, if any
+	last := 0
+	for _, m := range wikiMacroTag.FindAllStringSubmatchIndex(s, -1) {
+		tag, name := s[m[0]:m[1]], s[m[2]:m[3]]
+		if verbatim != "" {
+			if tag != "{"+verbatim+"}" {
+				continue
+			}
+			verbatim = ""
+		} else if name == "code" || name == "noformat" {
+			verbatim = name
+		}
+		sb.WriteString(s[last:m[0]])
+		sb.WriteString("\n" + tag + "\n")
+		last = m[1]
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// convertWikiBlocks converts wiki markup lines to Markdown lines, recursing
+// into the bodies of panels and quotes.
+func convertWikiBlocks(lines []string) []string {
+	var out []string
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		if m := wikiMacroLine.FindStringSubmatch(line); m != nil {
+			name, params := m[1], m[2]
+			end := findWikiMacroEnd(lines, i+1, name)
+			body := trimBlankEdges(lines[i+1 : end])
+
+			switch name {
+			case "code", "noformat":
+				out = append(out, "```"+wikiCodeLanguage(name, params))
+				out = append(out, body...)
+				out = append(out, "```")
+			case "quote":
+				out = append(out, quoteLines(convertWikiBlocks(body))...)
+			default:
+				var inner []string
+				if label := panelLabel(name, wikiMacroParam(params, "title")); label != "" {
+					inner = append(inner, label, "")
+				}
+				inner = append(inner, convertWikiBlocks(body)...)
+				out = append(out, quoteLines(inner)...)
+			}
+
+			i = end
+			continue
+		}
+
+		if strings.HasPrefix(line, "|") {
+			var rows [][]string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+				rows = append(rows, splitWikiRow(strings.TrimSpace(lines[i])))
+			}
+			i--
+
+			if len(out) > 0 && out[len(out)-1] != "" {
+				out = append(out, "")
+			}
+			out = append(out, tableLines(rows)...)
+			out = append(out, "")
+			continue
+		}
+
+		switch {
+		case wikiRule.MatchString(line):
+			// Surround rules with blank lines so they are not read as a
+			// setext heading underline.
+			out = append(out, "", "---", "")
+		case wikiHeading.MatchString(line):
+			m := wikiHeading.FindStringSubmatch(line)
+			level := int(m[1][0] - '0')
+			out = append(out, strings.Repeat("#", level)+" "+convertWikiInline(m[2]))
+		case wikiQuote.MatchString(line):
+			out = append(out, "> "+convertWikiInline(wikiQuote.FindStringSubmatch(line)[1]))
+		case wikiList.MatchString(line):
+			m := wikiList.FindStringSubmatch(line)
+			out = append(out, wikiListItem(m[1], convertWikiInline(m[2])))
+		default:
+			out = append(out, convertWikiInline(line))
+		}
+	}
+
+	return out
+}
+
+// findWikiMacroEnd returns the index of the line closing the named macro, or
+// len(lines) if it is never closed. Code and noformat blocks nested in the
+// macro are skipped, so a closing tag quoted inside them does not count. The
+// body of a code or noformat macro is literal, so only its own closing tag
+// ends it.
+func findWikiMacroEnd(lines []string, start int, name string) int {
+	closing := "{" + name + "}"
+	literal := name == "code" || name == "noformat"
+	for i := start; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == closing {
+			return i
+		}
+		if literal {
+			continue
+		}
+		if m := wikiMacroLine.FindStringSubmatch(line); m != nil && m[1] != name && (m[1] == "code" || m[1] == "noformat") {
+			i = findWikiMacroEnd(lines, i+1, m[1])
+		}
+	}
+	return len(lines)
+}
+
+// wikiCodeLanguage picks the fence language for a code macro. Parameters are
+// either a bare language ("{code:java}") or key=value pairs.
+func wikiCodeLanguage(name, params string) string {
+	if name != "code" || params == "" {
+		return ""
+	}
+	if lang := wikiMacroParam(params, "language"); lang != "" {
+		return lang
+	}
+	first := strings.Split(params, "|")[0]
+	if strings.Contains(first, "=") {
+		return ""
+	}
+	return strings.ToLower(first)
+}
+
+// wikiMacroParam returns a key=value parameter from a macro's parameter list.
+func wikiMacroParam(params, key string) string {
+	for _, p := range strings.Split(params, "|") {
+		k, v, ok := strings.Cut(p, "=")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// wikiListItem renders a list item with the given wiki markers, e.g. "*#",
+// indenting it under its parent items.
+func wikiListItem(markers, text string) string {
+	var indent strings.Builder
+	for _, m := range markers[:len(markers)-1] {
+		if m == '#' {
+			indent.WriteString("   ")
+		} else {
+			indent.WriteString("  ")
+		}
+	}
+
+	bullet := "-"
+	if markers[len(markers)-1] == '#' {
+		bullet = "1."
+	}
+
+	return fmt.Sprintf("%s%s %s", indent.String(), bullet, text)
+}
+
+// splitWikiRow splits a table row ("||a||b||" or "|a|b|") into cells. Inline
+// markup is converted first so link separators are not mistaken for cells,
+// and line breaks become <br> so they do not split the row.
+func splitWikiRow(row string) []string {
+	row = strings.ReplaceAll(convertWikiInline(row), "\n", "<br>")
+	row = strings.ReplaceAll(row, "||", "|")
+	row = strings.TrimPrefix(row, "|")
+	row = strings.TrimSuffix(row, "|")
+	return strings.Split(row, "|")
+}
+
+// trimBlankEdges drops leading and trailing blank lines.
+func trimBlankEdges(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// convertWikiInline converts inline wiki markup within a single line.
+func convertWikiInline(s string) string {
+	s = wikiMono.ReplaceAllString(s, "`$1`")
+
+	// Protect code spans from the remaining conversions.
+	var spans []string
+	s = wikiCodeSpan.ReplaceAllStringFunc(s, func(span string) string {
+		spans = append(spans, span)
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+
+	s = wikiColor.ReplaceAllString(s, "")
+	s = wikiAnchor.ReplaceAllString(s, "")
+	s = wikiMention.ReplaceAllString(s, "@$1")
+	s = wikiLinkText.ReplaceAllString(s, "[$1]($2)")
+	s = wikiLinkBare.ReplaceAllString(s, "<$1>")
+	s = wikiLinkFile.ReplaceAllString(s, "$1")
+	s = wikiImage.ReplaceAllString(s, "![$1]($1)")
+	s = wikiCitation.ReplaceAllString(s, "_${1}_")
+	s = replaceUntilStable(wikiBold, s, "$1**$2**$3")
+	s = replaceUntilStable(wikiStrike, s, "$1~~$2~~$3")
+	s = replaceUntilStable(wikiUnderline, s, "$1$2$3")
+	s = strings.ReplaceAll(s, `\\`, "\n")
+
+	for i, span := range spans {
+		s = strings.Replace(s, fmt.Sprintf("\x00%d\x00", i), span, 1)
+	}
+
+	return s
+}
+
+// replaceUntilStable applies re repeatedly. The inline patterns consume the
+// delimiter on either side of a match, so adjacent matches such as
+// "*a* *b*" need more than one pass.
+func replaceUntilStable(re *regexp.Regexp, s, repl string) string {
+	for range 4 {
+		next := re.ReplaceAllString(s, repl)
+		if next == s {
+			break
+		}
+		s = next
+	}
+	return s
+}
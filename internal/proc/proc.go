@@ -0,0 +1,55 @@
+// Package proc runs external commands bound to a context.
+//
+// Commands run in their own process group so that cancelling the context
+// (Ctrl-C, or a step timeout) stops the whole tree of processes a tool such
+// as claude or gh may have spawned, not just its direct child.
+package proc
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// GracePeriod is how long a cancelled command's process group has to exit
+// after being asked to terminate before it is killed.
+const GracePeriod = 5 * time.Second
+
+// Command returns a command that runs in its own process group and is
+// terminated, along with its children, when ctx is done.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return terminate(cmd)
+	}
+	// Don't let a grandchild holding stdout open block Wait forever.
+	cmd.WaitDelay = 2 * GracePeriod
+	return cmd
+}
+
+// WithTimeout returns a copy of ctx that is cancelled after timeout. A zero
+// or negative timeout leaves ctx without a deadline.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Err explains a command failure caused by ctx being done. Interrupts are
+// reported as context.Canceled and timeouts as context.DeadlineExceeded so
+// callers can tell them apart from ordinary failures; any other error is
+// returned unchanged.
+func Err(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return pkgerrors.Wrap(ctx.Err(), "timed out")
+	case errors.Is(ctx.Err(), context.Canceled):
+		return pkgerrors.Wrap(ctx.Err(), "interrupted")
+	}
+	return err
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package proc
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminate kills the command. Process groups are only managed on Unix.
+func terminate(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
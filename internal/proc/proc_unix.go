@@ -0,0 +1,26 @@
+//go:build unix
+
+package proc
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminate asks the command's process group to exit, then kills whatever is
+// left once the grace period is over.
+func terminate(cmd *exec.Cmd) error {
+	pgid := cmd.Process.Pid
+	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+		return cmd.Process.Kill()
+	}
+	time.AfterFunc(GracePeriod, func() {
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+	})
+	return nil
+}
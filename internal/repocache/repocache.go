@@ -0,0 +1,102 @@
+// Package repocache keeps local clones of repositories that tickets are
+// routed to by clone URL.
+package repocache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bsaliba1/jira-claude/internal/git"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// IsURL reports whether repo is a clone URL, either with a scheme such as
+// https:// or ssh://, or in scp-like form such as git@github.com:acme/api.git.
+func IsURL(repo string) bool {
+	if strings.Contains(repo, "://") {
+		return true
+	}
+	host, _, ok := strings.Cut(repo, ":")
+	return ok && strings.Contains(host, "@") && !strings.ContainsAny(host, `/\`)
+}
+
+// Cache clones repositories under dir, at a path derived from their URL.
+type Cache struct {
+	dir string
+}
+
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Path returns where url is cloned, e.g. <dir>/github.com/acme/api for
+// git@github.com:acme/api.git.
+func (c *Cache) Path(url string) (string, error) {
+	rest := url
+	_, afterScheme, hasScheme := strings.Cut(rest, "://")
+	if hasScheme {
+		rest = afterScheme
+	}
+	if i := strings.Index(rest, "@"); i >= 0 && i < strings.IndexAny(rest+"/", ":/") {
+		rest = rest[i+1:]
+	}
+	if hasScheme {
+		// Drop any port from the host.
+		host, path, _ := strings.Cut(rest, "/")
+		host, _, _ = strings.Cut(host, ":")
+		rest = host + "/" + path
+	} else {
+		rest = strings.Replace(rest, ":", "/", 1)
+	}
+	rest = strings.TrimSuffix(strings.TrimSuffix(rest, "/"), ".git")
+
+	var parts []string
+	for _, part := range strings.Split(rest, "/") {
+		if part == "" || part == "." || part == ".." {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) < 2 {
+		return "", fmt.Errorf("cannot derive a clone path from %q", url)
+	}
+	return filepath.Join(append([]string{c.dir}, parts...)...), nil
+}
+
+// Cloned reports whether url has already been cloned into the cache.
+func (c *Cache) Cloned(url string) (string, bool, error) {
+	path, err := c.Path(url)
+	if err != nil {
+		return "", false, err
+	}
+	_, err = os.Stat(filepath.Join(path, ".git"))
+	return path, err == nil, nil
+}
+
+// Ensure returns the local clone of url, cloning it first if needed. An
+// existing clone is returned as is; callers fetch or pull as usual.
+func (c *Cache) Ensure(ctx context.Context, url string) (string, error) {
+	path, cloned, err := c.Cloned(url)
+	if err != nil {
+		return "", err
+	}
+	if cloned {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", pkgerrors.Wrap(err, "failed to create repository cache directory")
+	}
+
+	// Clones can take a long time, so they are bounded only by ctx.
+	log.Ctx(ctx).Info().Str("url", url).Str("path", path).Msg("cloning repository")
+	if err := git.New(filepath.Dir(path)).Clone(ctx, url, path); err != nil {
+		os.RemoveAll(path)
+		return "", pkgerrors.Wrapf(err, "failed to clone %s", url)
+	}
+	return path, nil
+}
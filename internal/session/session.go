@@ -0,0 +1,82 @@
+// Package session persists the Claude session that last worked on each
+// ticket so a later run can resume it.
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when no session has been saved for a ticket.
+var ErrNotFound = errors.New("no saved session")
+
+// Session records where a ticket's work lives and which Claude session
+// produced it.
+type Session struct {
+	TicketKey     string    `json:"ticket_key"`
+	TicketSummary string    `json:"ticket_summary"`
+	SessionID     string    `json:"session_id"`
+	RepoPath      string    `json:"repo_path"`
+	Branch        string    `json:"branch"`
+	BaseBranch    string    `json:"base_branch"`
+	PRURL         string    `json:"pr_url,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	// WorkDir is the directory Claude ran in, which Claude Code keys its
+	// sessions by. It is a worktree rather than RepoPath when Worktree is
+	// set.
+	WorkDir  string `json:"work_dir,omitempty"`
+	Worktree bool   `json:"worktree,omitempty"`
+}
+
+// Store saves sessions as one JSON file per ticket in a directory.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) path(ticketKey string) string {
+	return filepath.Join(s.dir, ticketKey+".json")
+}
+
+// Save writes the session, replacing any previous session for the ticket.
+func (s *Store) Save(sess *Session) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return pkgerrors.Wrap(err, "failed to create session directory")
+	}
+
+	sess.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to encode session")
+	}
+
+	if err := os.WriteFile(s.path(sess.TicketKey), data, 0o644); err != nil {
+		return pkgerrors.Wrapf(err, "failed to save session for %s", sess.TicketKey)
+	}
+	return nil
+}
+
+// Load returns the saved session for the ticket, or ErrNotFound.
+func (s *Store) Load(ticketKey string) (*Session, error) {
+	data, err := os.ReadFile(s.path(ticketKey))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, pkgerrors.Wrapf(ErrNotFound, "ticket %s", ticketKey)
+	}
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "failed to read session for %s", ticketKey)
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, pkgerrors.Wrapf(err, "failed to parse session for %s", ticketKey)
+	}
+	return &sess, nil
+}
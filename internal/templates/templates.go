@@ -0,0 +1,226 @@
+// Package templates renders the prompts, commit messages and PR bodies
+// jira-claude produces from text/template files.
+//
+// Defaults are embedded in the binary. Any of them can be overridden by a
+// file of the same name in the global template directory, which in turn is
+// overridden by one in the repository's .jira-claude/templates directory.
+// All templates share one namespace, so an override can also reuse a partial
+// such as {{template "ticket_context" .}}.
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/bsaliba1/jira-claude/internal/github"
+	"github.com/bsaliba1/jira-claude/internal/jira"
+	"github.com/bsaliba1/jira-claude/internal/markup"
+	"github.com/bsaliba1/jira-claude/internal/verify"
+	pkgerrors "github.com/pkg/errors"
+)
+
+// Template names. Override a template by creating <name>.tmpl.
+const (
+	TicketPrompt            = "ticket_prompt"
+	PlanPrompt              = "plan_prompt"
+	PRBody                  = "pr_body"
+	CommitMessage           = "commit_message"
+	DescribePrompt          = "describe_prompt"
+	FollowUpCommitMessage   = "followup_commit_message"
+	PRCommentsPrompt        = "pr_comments_prompt"
+	PRCommentsCommitMessage = "pr_comments_commit_message"
+)
+
+// RepoDir is where a repository keeps its template overrides, relative to
+// its root.
+const RepoDir = ".jira-claude/templates"
+
+const ext = ".tmpl"
+
+//go:embed defaults/*.tmpl
+var defaults embed.FS
+
+// TicketData is passed to the ticket prompt, plan prompt, PR body and commit
+// message templates.
+type TicketData struct {
+	Ticket       *jira.Ticket
+	PromptPrefix string
+	// Plan is the approved implementation plan when --plan was used.
+	Plan string
+	Run  Run
+}
+
+// Run describes the work run a template is rendered for. Fields that are not
+// known yet at the point a template is rendered are left empty.
+type Run struct {
+	RepoPath   string
+	Branch     string
+	BaseBranch string
+	TicketURL  string
+	// Description is the PR description Claude wrote from the diff.
+	Description string
+	// GeneratedCommitMessage is the commit message Claude wrote from the
+	// diff, ending with a reference to the ticket.
+	GeneratedCommitMessage string
+	Verification           *verify.Report
+	// FollowUp is the instruction given with --resume.
+	FollowUp string
+}
+
+// DescribeData is passed to the describe_prompt template.
+type DescribeData struct {
+	Ticket *jira.Ticket
+	// Diff is the staged diff, cut short if it is very long.
+	Diff         string
+	Verification *verify.Report
+	// CommitMarker and PRMarker must appear in the prompt as given: Claude's
+	// reply is split on them.
+	CommitMarker string
+	PRMarker     string
+}
+
+// PRCommentsData is passed to the address-pr-comments templates.
+type PRCommentsData struct {
+	PR           *github.PRComments
+	PromptPrefix string
+}
+
+// Set is a loaded set of templates.
+type Set struct {
+	tmpl    *template.Template
+	sources map[string]string
+	texts   map[string]string
+}
+
+var funcs = template.FuncMap{
+	"markdown":   markup.ToMarkdown,
+	"size":       formatSize,
+	"downloaded": downloaded,
+	"join":       func(elems []string, sep string) string { return strings.Join(elems, sep) },
+	"trim":       strings.TrimSpace,
+	"add":        func(a, b int) int { return a + b },
+}
+
+// Load parses the embedded defaults and then any overrides found in dirs,
+// later directories taking precedence. Missing directories are skipped.
+func Load(dirs ...string) (*Set, error) {
+	s := &Set{
+		tmpl:    template.New("").Funcs(funcs),
+		sources: make(map[string]string),
+		texts:   make(map[string]string),
+	}
+
+	entries, err := fs.ReadDir(defaults, "defaults")
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to read default templates")
+	}
+	for _, entry := range entries {
+		path := "defaults/" + entry.Name()
+		text, err := defaults.ReadFile(path)
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "failed to read default template %s", entry.Name())
+		}
+		if err := s.parse(entry.Name(), string(text), "built-in"); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		paths, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "failed to list templates in %s", dir)
+		}
+		for _, path := range paths {
+			text, err := os.ReadFile(path)
+			if err != nil {
+				return nil, pkgerrors.Wrapf(err, "failed to read template %s", path)
+			}
+			if err := s.parse(filepath.Base(path), string(text), path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Set) parse(file, text, source string) error {
+	name := strings.TrimSuffix(file, ext)
+	if _, err := s.tmpl.New(name).Parse(text); err != nil {
+		return pkgerrors.Wrapf(err, "failed to parse template %s", source)
+	}
+	s.sources[name] = source
+	s.texts[name] = text
+	return nil
+}
+
+// Render executes the named template. Trailing whitespace is trimmed so
+// templates can end with a newline.
+func (s *Set) Render(name string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := s.tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", pkgerrors.Wrapf(err, "failed to render template %s (%s)", name, s.sources[name])
+	}
+	return strings.TrimRight(buf.String(), " \t\n"), nil
+}
+
+// Sources maps each template name to where it was loaded from: "built-in"
+// or the path of the overriding file.
+func (s *Set) Sources() map[string]string {
+	out := make(map[string]string, len(s.sources))
+	for name, source := range s.sources {
+		out[name] = source
+	}
+	return out
+}
+
+// Text returns the source text of the named template as loaded, so users
+// can start an override from the effective template.
+func (s *Set) Text(name string) (string, bool) {
+	text, ok := s.texts[name]
+	return text, ok
+}
+
+// Names returns the names of all loaded templates, sorted.
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.sources))
+	for name := range s.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// downloaded returns the attachments that have been saved to disk.
+func downloaded(attachments []jira.Attachment) []jira.Attachment {
+	var out []jira.Attachment
+	for _, a := range attachments {
+		if a.Path != "" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,160 @@
+// Package verify runs a repository's build and test command after Claude has
+// made its changes, so failures can be fed back to Claude before a PR is
+// opened.
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bsaliba1/jira-claude/internal/proc"
+)
+
+// MaxOutputChars caps how much of a failing command's output is kept. The
+// end of the output is kept since that is where most tools report failures.
+const MaxOutputChars = 8000
+
+var makeTestTarget = regexp.MustCompile(`(?m)^test:`)
+
+// Detect guesses a verify command from the files at the root of dir. It
+// returns "" if no known toolchain is found.
+func Detect(dir string) string {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "Makefile")); err == nil && makeTestTarget.Match(data) {
+		return "make test"
+	}
+
+	switch {
+	case exists("go.mod"):
+		return "go build ./... && go vet ./... && go test ./..."
+	case exists("Cargo.toml"):
+		return "cargo test"
+	case exists("package.json"):
+		if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil && bytes.Contains(data, []byte(`"test"`)) {
+			return "npm test"
+		}
+	case exists("pyproject.toml"), exists("pytest.ini"), exists("setup.py"):
+		return "pytest"
+	case exists("pom.xml"):
+		return "mvn -q verify"
+	case exists("build.gradle"), exists("build.gradle.kts"):
+		if exists("gradlew") {
+			return "./gradlew check"
+		}
+		return "gradle check"
+	}
+
+	return ""
+}
+
+// Result is the outcome of one run of the verify command.
+type Result struct {
+	Command  string
+	Passed   bool
+	Output   string
+	Duration time.Duration
+}
+
+// Run runs command with sh in dir, stopping it after timeout (zero for no
+// limit). A failing command is not an error; err is only set when ctx was
+// cancelled or the command could not be started.
+func Run(ctx context.Context, dir, command string, timeout time.Duration) (*Result, error) {
+	ctx, cancel := proc.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := proc.Command(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	start := time.Now()
+	runErr := cmd.Run()
+	result := &Result{
+		Command:  command,
+		Passed:   runErr == nil,
+		Output:   Trim(out.String(), MaxOutputChars),
+		Duration: time.Since(start),
+	}
+
+	if runErr != nil {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			result.Output = Trim(fmt.Sprintf("%s\n\n(verify command timed out after %s)", out.String(), timeout), MaxOutputChars)
+		case ctx.Err() != nil:
+			return result, proc.Err(ctx, runErr)
+		case cmd.ProcessState == nil:
+			return result, fmt.Errorf("failed to start verify command: %w", runErr)
+		}
+	}
+
+	return result, nil
+}
+
+// Trim keeps at most max characters from the end of s, cutting at a line
+// boundary and noting how much was dropped.
+func Trim(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= max {
+		return s
+	}
+
+	tail := s[len(s)-max:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	}
+	return fmt.Sprintf("... (%d earlier characters omitted)\n%s", len(s)-len(tail), tail)
+}
+
+// Report summarizes the verify loop for a ticket: the command, whether it
+// finally passed, how many times it ran and the output of the last failure.
+type Report struct {
+	Command  string
+	Passed   bool
+	Runs     int
+	Output   string
+	Duration time.Duration
+}
+
+// Record folds a verify run into the report.
+func (r *Report) Record(res *Result) {
+	r.Command = res.Command
+	r.Passed = res.Passed
+	r.Runs++
+	r.Duration += res.Duration
+	if res.Passed {
+		r.Output = ""
+	} else {
+		r.Output = res.Output
+	}
+}
+
+// FixPrompt asks Claude to fix the failures reported by a verify run.
+func FixPrompt(r *Result) string {
+	var sb strings.Builder
+	sb.WriteString("The verification command failed after your changes.\n\n")
+	sb.WriteString(fmt.Sprintf("Command: `%s`\n\n", r.Command))
+	sb.WriteString("Output:\n```\n")
+	sb.WriteString(r.Output)
+	sb.WriteString("\n```\n\n")
+	sb.WriteString("Please fix the code so the command passes. Do not weaken or delete tests to make them pass, ")
+	sb.WriteString("and do not change the verification command itself.\n")
+	return sb.String()
+}
+
+// FixAttempts is how many times Claude was asked to fix a failing run.
+func (r *Report) FixAttempts() int {
+	return max(r.Runs-1, 0)
+}